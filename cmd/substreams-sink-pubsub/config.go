@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	. "github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
+
+	spubsub "github.com/streamingfast/substreams-sink-pubsub"
+)
+
+var toolsConfigGroup = Group("config", "Inspect the sink's configuration file",
+	Command(toolsConfigValidateE,
+		"validate <config-file>",
+		"Check a configuration file, as passed to '--config'",
+		ExactArgs(1),
+		Description(`
+			Check a configuration file: unknown keys, invalid values and flags that don't exist
+			are reported. The file is in YAML ('.yaml' or '.yml') or TOML ('.toml'):
+
+			  substreams:
+			    endpoint: mainnet.eth.streamingfast.io:443  # --endpoint
+			    network: mainnet                             # --network
+			    manifest: ./substreams.yaml                  # <manifest-path>
+			    module: map_clocks                           # <module-name>
+			    block_range: "0:1000"                        # <block-range>
+			    params: ["map_clocks=1"]                     # --params
+			    undo_buffer_size: 12                         # --undo-buffer-size
+			    final_blocks_only: false                     # --final-blocks-only
+			  topic: dev-topic                               # <topic-name>
+			  destination: pubsub                            # --destination
+			  cursor_path: ./state                           # --cursor_path
+			  checkpoint:
+			    blocks: 1000                                 # --checkpoint-blocks
+			    interval: 10s                                # --checkpoint-interval
+			  publish:
+			    envelope: message                            # --envelope
+			    block_end_marker: false                      # --block-end-marker
+			    heartbeat_interval: 30s                      # --heartbeat-interval
+			    create_topic_if_missing: false               # --create-topic-if-missing
+			    drain_timeout: 30s                           # --drain-timeout
+			  health:
+			    listen_addr: ":8080"                         # --health-listen-addr
+			    block_timeout: 5m                            # --health-block-timeout
+			    max_publish_errors: 100                      # --health-max-publish-errors
+			  pubsub:
+			    project: acme                                # --project
+			    endpoint: pubsub.googleapis.com:443          # --pubsub-endpoint
+			    region: us-east1                             # --pubsub-region
+			    insecure: false                              # --pubsub-insecure
+			    credentials_file: ./key.json                 # --credentials-file
+			    impersonate_service_account: sa@acme.iam...  # --impersonate-service-account
+			    emulator: localhost:8085                     # --emulator
+			  webhook:
+			    per_block: true                              # --webhook-per-block
+			    timeout: 30s                                 # --webhook-timeout
+			    max_retries: 10                              # --webhook-max-retries
+			    concurrency: 8                               # --webhook-concurrency
+			    secret_envvar: SUBSTREAMS_SINK_WEBHOOK_SECRET # --webhook-secret-envvar
+			  attributes:                                    # added to every message
+			    Env: prod
+			  filters:                                       # keep messages whose attribute
+			    - attribute: Type                            # is one of the values, every
+			      values: [Transfer, Approval]               # filter must keep a message
+			  rename_attributes:                             # renamed after the filters
+			    Type: EventType
+
+			Values given on the command line take precedence over 'PUBSUB_SINK_*' environment
+			variables, which take precedence over the file. Other flags are not part of the
+			file. A process publishes to a single destination and topic, routing messages to
+			several topics is not supported, but the topic of some destinations is a template,
+			see 'sink --help'. Filters and renames are rejected with '--envelope=block'.
+		`),
+	),
+)
+
+func toolsConfigValidateE(cmd *cobra.Command, args []string) error {
+	config, err := spubsub.LoadConfig(args[0])
+	if err != nil {
+		return err
+	}
+
+	flags := pflag.NewFlagSet("sink", pflag.ContinueOnError)
+	addSinkFlags(flags)
+
+	if err := applyConfigFlags(flags, configFlagValues(config)); err != nil {
+		return fmt.Errorf("invalid config file %q: %w", args[0], err)
+	}
+
+	if _, err := configOptions(config, flags.Lookup("envelope").Value.String()); err != nil {
+		return fmt.Errorf("invalid config file %q: %w", args[0], err)
+	}
+
+	var missing []string
+	for name, value := range map[string]string{"substreams.manifest": config.Substreams.Manifest, "substreams.module": config.Substreams.Module, "topic": config.Topic} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	fmt.Printf("Config file %s is valid\n", args[0])
+	if len(missing) > 0 {
+		fmt.Printf("Not set, to be passed on the command line: %s\n", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// loadSinkConfig loads the '--config' file, if any, setting the flags that were not set on
+// the command line. When args is empty, it's filled from the file's manifest, module, topic and
+// block range.
+func loadSinkConfig(cmd *cobra.Command, args []string) (*spubsub.Config, []string, error) {
+	path := sflags.MustGetString(cmd, "config")
+	if path == "" {
+		return &spubsub.Config{}, args, nil
+	}
+
+	config, err := spubsub.LoadConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := applyConfigFlags(cmd.Flags(), configFlagValues(config)); err != nil {
+		return nil, nil, fmt.Errorf("invalid config file %q: %w", path, err)
+	}
+
+	if len(args) == 0 {
+		args = []string{config.Substreams.Manifest, config.Substreams.Module, config.Topic}
+		if config.Substreams.BlockRange != "" {
+			args = append(args, config.Substreams.BlockRange)
+		}
+	}
+
+	return config, args, nil
+}
+
+// configOptions returns the options applying config's filters and attribute renames. They
+// are rejected with the block envelope, whose messages don't carry the module's attributes.
+func configOptions(config *spubsub.Config, envelope string) ([]spubsub.Option, error) {
+	if (len(config.Filters) > 0 || len(config.RenameAttributes) > 0) && envelope == "block" {
+		return nil, fmt.Errorf("config file 'filters' and 'rename_attributes' are not supported with '--envelope=block'")
+	}
+
+	return config.Options(), nil
+}
+
+// configFlagValues returns the values of the flags set by config, keyed by flag name.
+func configFlagValues(config *spubsub.Config) map[string][]string {
+	values := map[string][]string{}
+
+	set := func(name string, value string) {
+		if value != "" {
+			values[name] = []string{value}
+		}
+	}
+	setBool := func(name string, value *bool) {
+		if value != nil {
+			values[name] = []string{strconv.FormatBool(*value)}
+		}
+	}
+	setInt := func(name string, value *int) {
+		if value != nil {
+			values[name] = []string{strconv.Itoa(*value)}
+		}
+	}
+
+	set("endpoint", config.Substreams.Endpoint)
+	set("network", config.Substreams.Network)
+	if len(config.Substreams.Params) > 0 {
+		values["params"] = config.Substreams.Params
+	}
+	setInt("undo-buffer-size", config.Substreams.UndoBufferSize)
+	setBool("final-blocks-only", config.Substreams.FinalBlocksOnly)
+	set("destination", config.Destination)
+	set("cursor_path", config.CursorPath)
+
+	setInt("checkpoint-blocks", config.Checkpoint.Blocks)
+	set("checkpoint-interval", config.Checkpoint.Interval)
+
+	set("envelope", config.Publish.Envelope)
+	setBool("block-end-marker", config.Publish.BlockEndMarker)
+	set("heartbeat-interval", config.Publish.HeartbeatInterval)
+	setBool("create-topic-if-missing", config.Publish.CreateTopicIfMissing)
+	set("drain-timeout", config.Publish.DrainTimeout)
+
+	set("health-listen-addr", config.Health.ListenAddr)
+	set("health-block-timeout", config.Health.BlockTimeout)
+	setInt("health-max-publish-errors", config.Health.MaxPublishErrors)
+
+	set("project", config.PubSub.Project)
+	set("pubsub-endpoint", config.PubSub.Endpoint)
+	set("pubsub-region", config.PubSub.Region)
+	setBool("pubsub-insecure", config.PubSub.Insecure)
+	set("credentials-file", config.PubSub.CredentialsFile)
+	set("impersonate-service-account", config.PubSub.ImpersonateServiceAccount)
+	set("emulator", config.PubSub.Emulator)
+
+	setBool("webhook-per-block", config.Webhook.PerBlock)
+	set("webhook-timeout", config.Webhook.Timeout)
+	setInt("webhook-max-retries", config.Webhook.MaxRetries)
+	setInt("webhook-concurrency", config.Webhook.Concurrency)
+	set("webhook-secret-envvar", config.Webhook.SecretEnvvar)
+
+	return values
+}
+
+// applyConfigFlags sets the flags that were neither set on the command line nor through a
+// 'PUBSUB_SINK_*' environment variable to values, marking them as changed, so that a value
+// from the file is read like one from the command line.
+func applyConfigFlags(flags *pflag.FlagSet, values map[string][]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" {
+			return fmt.Errorf("flag 'config' cannot be set from the config file")
+		}
+
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("flag %q does not exist", name)
+		}
+
+		if flag.Changed || flagEnvSet(flag) {
+			continue
+		}
+
+		for _, value := range values[name] {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("flag %q value %q is invalid: %w", name, value, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// envKeyReplacer maps a flag's bound key to its environment variable, as [ConfigureViper] does.
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// flagEnvSet returns true if the flag is set through its 'PUBSUB_SINK_*' environment variable,
+// named after the key bound by [ConfigureViper].
+func flagEnvSet(flag *pflag.Flag) bool {
+	for _, key := range flag.Annotations[ReboundFlagAnnotation] {
+		if _, found := os.LookupEnv(envPrefix + "_" + strings.ToUpper(envKeyReplacer.Replace(key))); found {
+			return true
+		}
+	}
+
+	return false
+}
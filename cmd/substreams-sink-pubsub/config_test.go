@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/streamingfast/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyConfigFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("sink", pflag.ContinueOnError)
+	addSinkFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--destination", "stdout"}))
+
+	flags.Lookup("endpoint").Annotations = map[string][]string{cli.ReboundFlagAnnotation: {"sink.endpoint"}}
+	t.Setenv("PUBSUB_SINK_SINK_ENDPOINT", "env.streamingfast.io:443")
+
+	require.NoError(t, applyConfigFlags(flags, map[string][]string{
+		"destination":       {"kafka://localhost:9092"},
+		"endpoint":          {"file.streamingfast.io:443"},
+		"checkpoint-blocks": {"1000"},
+		"params":            {"map_a=1", "map_b=2"},
+	}))
+
+	// The command line takes precedence over the file
+	require.Equal(t, "stdout", flags.Lookup("destination").Value.String())
+
+	// The environment variable takes precedence over the file, the flag is left unchanged for
+	// it to be read
+	require.False(t, flags.Lookup("endpoint").Changed)
+	require.Equal(t, "", flags.Lookup("endpoint").Value.String())
+
+	checkpointBlocks := flags.Lookup("checkpoint-blocks")
+	require.True(t, checkpointBlocks.Changed)
+	require.Equal(t, "1000", checkpointBlocks.Value.String())
+
+	params, err := flags.GetStringArray("params")
+	require.NoError(t, err)
+	require.Equal(t, []string{"map_a=1", "map_b=2"}, params)
+
+	require.ErrorContains(t, applyConfigFlags(flags, map[string][]string{"unknown": {"1"}}), `flag "unknown" does not exist`)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/streamingfast/cli/sflags"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+// addPubSubFlags adds the flags read by [newPubSubClient].
+func addPubSubFlags(flags *pflag.FlagSet) {
+	flags.String("project", "", "Google Cloud Project ID, detected from the environment if unset")
+	flags.String("pubsub-endpoint", "", "PubSub API endpoint as '<host>:<port>', the global 'pubsub.googleapis.com:443' endpoint if unset")
+	flags.String("pubsub-region", "", "Use the regional endpoint of this region (e.g. 'us-east1'), so messages are handled and stored in that region, exclusive with '--pubsub-endpoint'")
+	flags.Bool("pubsub-insecure", false, "Connect to '--pubsub-endpoint' without TLS nor authentication, for emulators and local proxies")
+	flags.String("credentials-file", "", "Path to a service account key or other credentials JSON file, Application Default Credentials are used if unset")
+	flags.String("impersonate-service-account", "", "Email of a service account to impersonate, the credentials need the 'Service Account Token Creator' role on it")
+	flags.String("emulator", "", "Address of a PubSub emulator, like the one started by the 'emulator' command (e.g. 'localhost:8085'), shorthand for '--pubsub-endpoint <address> --pubsub-insecure' taking precedence over the 'PUBSUB_EMULATOR_HOST' environment variable")
+}
+
+// pubSubClient is a PubSub client also closing the gRPC connection it was created with, if any,
+// which [pubsub.Client.Close] is not guaranteed to close.
+type pubSubClient struct {
+	*pubsub.Client
+	conn *grpc.ClientConn
+}
+
+func (c *pubSubClient) Close() error {
+	err := c.Client.Close()
+	if c.conn != nil && c.conn.GetState() != connectivity.Shutdown {
+		err = errors.Join(err, c.conn.Close())
+	}
+
+	return err
+}
+
+// newPubSubClient creates a PubSub client for the '--project' flag, detecting the project from
+// the environment if unset. The endpoint and credentials are the ones of the flags added by
+// [addPubSubFlags], the 'PUBSUB_EMULATOR_HOST' environment variable redirects the client to an
+// emulator when none is set.
+func newPubSubClient(ctx context.Context, cmd *cobra.Command) (*pubSubClient, error) {
+	projectID := sflags.MustGetString(cmd, "project")
+
+	opts, conn, err := pubSubClientOptions(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if projectID == "" {
+		if conn != nil {
+			conn.Close()
+			return nil, fmt.Errorf("'--project' is required with '--emulator' or '--pubsub-insecure'")
+		}
+		projectID = pubsub.DetectProjectID
+	}
+
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, fmt.Errorf("creating pubsub client: %w", err)
+	}
+
+	return &pubSubClient{Client: client, conn: conn}, nil
+}
+
+// pubSubClientOptions returns the client options of the flags added by [addPubSubFlags] and,
+// when they point to an endpoint without TLS nor authentication, the connection to it that the
+// caller must close.
+func pubSubClientOptions(ctx context.Context, cmd *cobra.Command) (opts []option.ClientOption, conn *grpc.ClientConn, err error) {
+	endpoint := sflags.MustGetString(cmd, "pubsub-endpoint")
+	insecureEndpoint := sflags.MustGetBool(cmd, "pubsub-insecure")
+	credentialsFile := sflags.MustGetString(cmd, "credentials-file")
+	serviceAccount := sflags.MustGetString(cmd, "impersonate-service-account")
+
+	if region := sflags.MustGetString(cmd, "pubsub-region"); region != "" {
+		if endpoint != "" {
+			return nil, nil, fmt.Errorf("'--pubsub-region' and '--pubsub-endpoint' are exclusive")
+		}
+		endpoint = fmt.Sprintf("%s-pubsub.googleapis.com:443", region)
+	}
+
+	if emulator := sflags.MustGetString(cmd, "emulator"); emulator != "" {
+		if endpoint != "" {
+			return nil, nil, fmt.Errorf("'--emulator' cannot be combined with '--pubsub-endpoint' or '--pubsub-region'")
+		}
+		endpoint = emulator
+		insecureEndpoint = true
+	}
+
+	if insecureEndpoint {
+		if endpoint == "" {
+			return nil, nil, fmt.Errorf("'--pubsub-insecure' requires '--pubsub-endpoint'")
+		}
+		if credentialsFile != "" || serviceAccount != "" {
+			return nil, nil, fmt.Errorf("'--credentials-file' and '--impersonate-service-account' cannot be used with an insecure endpoint")
+		}
+
+		return insecureClientOptions(endpoint)
+	}
+
+	if host := os.Getenv("PUBSUB_EMULATOR_HOST"); host != "" && (endpoint != "" || credentialsFile != "" || serviceAccount != "") {
+		return nil, nil, fmt.Errorf("the 'PUBSUB_EMULATOR_HOST' environment variable (%q) would override the endpoint and credentials flags, unset it or use '--emulator'", host)
+	}
+
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+
+	credentialsOpts, err := credentialsClientOptions(ctx, cmd, pubsub.ScopePubSub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append(opts, credentialsOpts...), nil, nil
+}
+
+// credentialsClientOptions returns the client options of the '--credentials-file' and
+// '--impersonate-service-account' flags, shared by the Google Cloud clients. The impersonated
+// service account's tokens are limited to scopes.
+func credentialsClientOptions(ctx context.Context, cmd *cobra.Command, scopes ...string) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	if credentialsFile := sflags.MustGetString(cmd, "credentials-file"); credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	serviceAccount := sflags.MustGetString(cmd, "impersonate-service-account")
+	if serviceAccount == "" {
+		return opts, nil
+	}
+
+	tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: serviceAccount,
+		Scopes:          scopes,
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("impersonating service account %q: %w", serviceAccount, err)
+	}
+
+	return []option.ClientOption{option.WithTokenSource(tokenSource)}, nil
+}
+
+// insecureClientOptions returns the client options connecting to addr without TLS nor
+// authentication, and the connection, which the caller closes along with the client. The connection is dialed
+// explicitly so that it takes precedence over the 'PUBSUB_EMULATOR_HOST' environment variable.
+func insecureClientOptions(addr string) ([]option.ClientOption, *grpc.ClientConn, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("dialing %q: %w", addr, err)
+	}
+
+	return []option.ClientOption{option.WithGRPCConn(conn), option.WithTelemetryDisabled()}, conn, nil
+}
+
+// newEmulatorClient creates a PubSub client for the emulator listening on addr.
+func newEmulatorClient(ctx context.Context, projectID string, addr string) (*pubSubClient, error) {
+	opts, conn, err := insecureClientOptions(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("creating pubsub emulator client: %w", err)
+	}
+
+	return &pubSubClient{Client: client, conn: conn}, nil
+}
+
+// newPublisher creates the [publisher.Publisher] pointed to by the '--destination' flag,
+// publishing to the topic named topicName.
+func newPublisher(ctx context.Context, cmd *cobra.Command, topicName string) (publisher.Publisher, error) {
+	destination := sflags.MustGetString(cmd, "destination")
+
+	if destination == "" || destination == "pubsub" {
+		client, err := newPubSubClient(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		pubSubPublisher := publisher.NewPubSub(client.Client, client.Topic(topicName))
+		pubSubPublisher.SetClientCloser(client)
+
+		return pubSubPublisher, nil
+	}
+
+	if destination == "stdout" {
+		return publisher.NewWriterFile(os.Stdout), nil
+	}
+
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination %q: %w", destination, err)
+	}
+
+	switch destinationURL.Scheme {
+	case "kafka":
+		return publisher.NewKafka(strings.Split(destinationURL.Host, ","), topicName)
+	case "nats":
+		return publisher.NewNATS(destination, topicName)
+	case "redis", "rediss":
+		return newRedisPublisher(destinationURL, topicName)
+	case "amqp", "amqps":
+		return newAMQPPublisher(destinationURL, topicName)
+	case "http", "https":
+		return publisher.NewWebhook(publisher.WebhookConfig{
+			URL:         destination,
+			Topic:       topicName,
+			PerBlock:    sflags.MustGetBool(cmd, "webhook-per-block"),
+			Secret:      []byte(os.Getenv(sflags.MustGetString(cmd, "webhook-secret-envvar"))),
+			Timeout:     sflags.MustGetDuration(cmd, "webhook-timeout"),
+			MaxRetries:  sflags.MustGetInt(cmd, "webhook-max-retries"),
+			Concurrency: sflags.MustGetInt(cmd, "webhook-concurrency"),
+		})
+	case "file":
+		return newFilePublisher(destinationURL, topicName)
+	}
+
+	return nil, fmt.Errorf("unsupported destination %q, valid values are 'pubsub', 'kafka://<broker>[,<broker>...]', 'nats://<server>[,<server>...]', 'redis[s]://[<user>:<password>@]<host>:<port>[/<db>][?maxlen=<entries>]', 'amqp[s]://[<user>:<password>@]<host>:<port>[/<vhost>][?routing_key=<template>&undo_routing_key=<template>]', 'http[s]://<webhook-url>', 'file://<directory>[?rotate=<blocks>&gzip=true]' or 'stdout'", destination)
+}
+
+func newRedisPublisher(destinationURL *url.URL, stream string) (publisher.Publisher, error) {
+	query := destinationURL.Query()
+
+	var maxLen int64
+	if value := query.Get("maxlen"); value != "" {
+		var err error
+		if maxLen, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid 'maxlen' query parameter %q: %w", value, err)
+		}
+	}
+
+	// The remaining query parameters are Redis client options understood by redis.ParseURL
+	query.Del("maxlen")
+	destinationURL.RawQuery = query.Encode()
+
+	opts, err := redis.ParseURL(destinationURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis destination: %w", err)
+	}
+
+	return publisher.NewRedis(opts, stream, maxLen), nil
+}
+
+func newAMQPPublisher(destinationURL *url.URL, exchange string) (publisher.Publisher, error) {
+	query := destinationURL.Query()
+
+	config := publisher.AMQPConfig{
+		Exchange:   publisher.NewTemplate(exchange),
+		RoutingKey: publisher.NewTemplate(query.Get("routing_key")),
+	}
+	if query.Has("undo_routing_key") {
+		config.UndoRoutingKey = publisher.NewTemplate(query.Get("undo_routing_key"))
+	}
+
+	// The remaining query parameters are connection options understood by the AMQP client
+	query.Del("routing_key")
+	query.Del("undo_routing_key")
+	destinationURL.RawQuery = query.Encode()
+
+	return publisher.NewAMQP(destinationURL.String(), config)
+}
+
+func newFilePublisher(destinationURL *url.URL, name string) (publisher.Publisher, error) {
+	query := destinationURL.Query()
+
+	config := publisher.FileConfig{
+		// Host is non-empty for relative paths like 'file://./archive'
+		Directory: destinationURL.Host + destinationURL.Path,
+		Name:      name,
+		Gzip:      query.Get("gzip") == "true",
+	}
+
+	if value := query.Get("rotate"); value != "" {
+		var err error
+		if config.RotateEvery, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid 'rotate' query parameter %q: %w", value, err)
+		}
+	}
+
+	return publisher.NewFile(config)
+}
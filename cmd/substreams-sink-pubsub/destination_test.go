@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestEmulatorClientClosesConn(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	client, err := newEmulatorClient(context.Background(), "project", srv.Addr)
+	require.NoError(t, err)
+	require.NotNil(t, client.conn)
+
+	require.NoError(t, client.Close())
+	require.Equal(t, connectivity.Shutdown, client.conn.GetState())
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	. "github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
+	"go.uber.org/zap"
+)
+
+var emulatorCmd = Command(emulatorRunE,
+	"emulator",
+	"Run an in-memory PubSub emulator for local development",
+	NoArgs(),
+	Flags(func(flags *pflag.FlagSet) {
+		flags.Int("port", 8085, "Port the emulator listens on, on localhost")
+		flags.String("project", "acme", "Google Cloud Project ID the topics and subscriptions are created in")
+		flags.StringSlice("topic", nil, "Topic created on startup, can be repeated")
+		flags.StringSlice("subscription", nil, "Subscription created on startup, in the form '<subscription>:<topic>' with message ordering enabled, can be repeated")
+		flags.Bool("print", false, "Print the messages published to the emulator, like 'tools tail'")
+	}),
+	Description(`
+		Run an in-memory PubSub emulator, a lighter alternative to the gcloud emulator of
+		'docker-compose.yml'. Everything is lost when the emulator stops.
+
+		Point the sink and tools to it with '--emulator localhost:<port> --project <project>',
+		or by exporting 'PUBSUB_EMULATOR_HOST=localhost:<port>'.
+	`),
+	ExamplePrefixed("substreams-sink-pubsub emulator", `
+		# Create dev-topic and an ordered dev-topic-sub subscription, printing published messages
+		--topic dev-topic --subscription dev-topic-sub:dev-topic --print
+	`),
+)
+
+func emulatorRunE(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	port := sflags.MustGetInt(cmd, "port")
+	projectID := sflags.MustGetString(cmd, "project")
+
+	server, err := newPSTestServer(port)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	client, err := newEmulatorClient(ctx, projectID, server.Addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, topicName := range sflags.MustGetStringSlice(cmd, "topic") {
+		if _, err := client.CreateTopic(ctx, topicName); err != nil {
+			return fmt.Errorf("creating topic %q: %w", topicName, err)
+		}
+	}
+
+	for _, value := range sflags.MustGetStringSlice(cmd, "subscription") {
+		subscriptionName, topicName, found := strings.Cut(value, ":")
+		if !found || subscriptionName == "" || topicName == "" {
+			return fmt.Errorf("invalid '--subscription' %q, expected '<subscription>:<topic>'", value)
+		}
+
+		_, err := client.CreateSubscription(ctx, subscriptionName, pubsub.SubscriptionConfig{
+			Topic:                 client.Topic(topicName),
+			EnableMessageOrdering: true,
+		})
+		if err != nil {
+			return fmt.Errorf("creating subscription %q: %w", subscriptionName, err)
+		}
+	}
+
+	zlog.Info("emulator started", zap.String("addr", server.Addr), zap.String("project", projectID))
+	fmt.Printf("Emulator listening on %s, use '--emulator %s --project %s' or 'export PUBSUB_EMULATOR_HOST=%s'\n", server.Addr, server.Addr, projectID, server.Addr)
+
+	if sflags.MustGetBool(cmd, "print") {
+		printEmulatorMessages(ctx, server)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// newPSTestServer starts a [pstest.Server] on port, returning an error instead of panicking
+// if the port cannot be listened on.
+func newPSTestServer(port int) (server *pstest.Server, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("starting emulator on port %d: %v", port, r)
+		}
+	}()
+
+	return pstest.NewServerWithPort(port), nil
+}
+
+// printEmulatorMessages prints the messages published to the server, as 'tools tail' does,
+// until ctx is done.
+func printEmulatorMessages(ctx context.Context, server *pstest.Server) {
+	printed := map[string]bool{}
+	highlight := isTerminal(os.Stdout)
+
+	fmt.Printf(tailTableFormat+"\n", "PUBLISHED", "BLOCK", "STEP", "ATTRIBUTES / DATA")
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var messages []*pstest.Message
+		for _, message := range server.Messages() {
+			if !printed[message.ID] {
+				printed[message.ID] = true
+				messages = append(messages, message)
+			}
+		}
+
+		sort.Slice(messages, func(i, j int) bool {
+			return messages[i].PublishTime.Before(messages[j].PublishTime)
+		})
+
+		for _, message := range messages {
+			decoded := decodeTailMessage(&pubsub.Message{
+				ID:          message.ID,
+				Data:        message.Data,
+				Attributes:  message.Attributes,
+				PublishTime: message.PublishTime,
+				OrderingKey: message.OrderingKey,
+			})
+
+			fmt.Println(decoded.tableRow(0, highlight))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/streamingfast/cli/sflags"
+
+	spubsub "github.com/streamingfast/substreams-sink-pubsub"
+)
+
+// addLeaderElectionFlags adds the flags read by [newElector].
+func addLeaderElectionFlags(flags *pflag.FlagSet) {
+	flags.String("leader-election", "", "Run as one of several replicas sharing '--cursor_path', only the leader holding the lease in this 'gs://<bucket>/<object>' Google Cloud Storage object streams and saves the cursor, the others wait as hot standbys, disabled if empty")
+	flags.Duration("leader-election-ttl", 15*time.Second, "With '--leader-election', time after which the lease of a leader that stopped renewing it can be taken by a standby, it's renewed every third of it, a leader failing to renew it for two thirds of it stops, the block being handled may still be published after a standby took over if draining takes longer than the remaining third, see '--drain-timeout'")
+	flags.String("leader-election-id", "", "With '--leader-election', identity of this replica in the lease, '<hostname>-<pid>' if empty")
+}
+
+// newElector creates the elector of the '--leader-election' flag, nil if it's not set. The
+// Google Cloud Storage client uses the '--credentials-file' and '--impersonate-service-account'
+// flags, it's closed along with the elector.
+func newElector(ctx context.Context, cmd *cobra.Command) (*spubsub.Elector, error) {
+	rawLease := sflags.MustGetString(cmd, "leader-election")
+	if rawLease == "" {
+		return nil, nil
+	}
+
+	if sflags.MustGetBool(cmd, "dry-run") {
+		return nil, fmt.Errorf("'--leader-election' cannot be used with '--dry-run' which doesn't save the cursor")
+	}
+
+	leaseURL, err := url.Parse(rawLease)
+	if err != nil || leaseURL.Scheme != "gs" || leaseURL.Host == "" || strings.Trim(leaseURL.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid '--leader-election' %q, expected 'gs://<bucket>/<object>'", rawLease)
+	}
+
+	ttl := sflags.MustGetDuration(cmd, "leader-election-ttl")
+	if ttl < 3*time.Second {
+		return nil, fmt.Errorf("'--leader-election-ttl' must be at least 3s, got %s", ttl)
+	}
+
+	holder := sflags.MustGetString(cmd, "leader-election-id")
+	if holder == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("getting hostname for '--leader-election-id': %w", err)
+		}
+		holder = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	opts, err := credentialsClientOptions(ctx, cmd, storage.ScopeReadWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating storage client: %w", err)
+	}
+
+	store := spubsub.NewGCSLeaseStore(client, leaseURL.Host, strings.TrimPrefix(leaseURL.Path, "/"))
+	return spubsub.NewElector(store, holder, ttl, zlog), nil
+}
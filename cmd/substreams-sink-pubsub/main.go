@@ -7,11 +7,17 @@ import (
 
 var version = "dev"
 
+// envPrefix prefixes the environment variables setting the flags, see [cli.ConfigureViper].
+const envPrefix = "PUBSUB_SINK"
+
 func main() {
 	cli.Run("substreams-sink-pubsub", "Substreams PubSub sink",
 		sinkCmd,
+		replayCmd,
+		emulatorCmd,
+		toolsGroup,
 
-		cli.ConfigureViper("PUBSUB_SINK"),
+		cli.ConfigureViper(envPrefix),
 		cli.ConfigureVersion(version),
 		cli.OnCommandErrorLogAndExit(zlog),
 
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli/sflags"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+type preflightCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// preflightChecks checks that the destination accepts the sink's messages before streaming, so
+// that a misconfiguration fails on startup with an actionable error instead of on the first
+// published block. The module's output type is checked by sink.NewFromViper, before.
+func preflightChecks(ctx context.Context, cmd *cobra.Command, pub publisher.Publisher, topicName string) error {
+	pubSubPublisher, ok := pub.(*publisher.PubSub)
+	if !ok {
+		return nil
+	}
+
+	if err := runPreflightChecks(ctx, pubSubPreflightChecks(pubSubPublisher, topicName, sflags.MustGetBool(cmd, "create-topic-if-missing"))); err != nil {
+		return err
+	}
+
+	zlog.Info("preflight checks passed", zap.String("topic", topicName))
+	return nil
+}
+
+// runPreflightChecks runs the checks in order, logging whether each one passed, and returns
+// the error of the first failing one.
+func runPreflightChecks(ctx context.Context, checks []preflightCheck) error {
+	for _, check := range checks {
+		if err := check.run(ctx); err != nil {
+			zlog.Error("preflight check failed", zap.String("check", check.name), zap.Error(err))
+			return fmt.Errorf("preflight: %w", err)
+		}
+
+		zlog.Info("preflight check passed", zap.String("check", check.name))
+	}
+
+	return nil
+}
+
+// pubSubPreflightChecks returns the checks of a PubSub topic, creating it when missing if create
+// is true. When the publisher has message ordering enabled, which publishing messages with an
+// ordering key requires, every subscription of the topic must have it enabled too. The module's
+// 'Publish' messages have no ordering key field, so the check is skipped unless the publisher's
+// topic was configured for ordering keys set by transformers.
+func pubSubPreflightChecks(pub *publisher.PubSub, topicName string, create bool) []preflightCheck {
+	checks := []preflightCheck{
+		{
+			name: "topic exists",
+			run: func(ctx context.Context) error {
+				created, err := pub.EnsureTopic(ctx, create)
+				if errors.Is(err, publisher.ErrTopicNotFound) {
+					return fmt.Errorf("topic %q does not exist, create it with 'substreams-sink-pubsub tools topic create %s' or pass '--create-topic-if-missing'", topicName, topicName)
+				}
+				if err != nil {
+					return err
+				}
+				if created {
+					zlog.Info("created missing topic", zap.String("topic", topicName))
+				}
+				return nil
+			},
+		},
+		{
+			name: "publish permission",
+			run: func(ctx context.Context) error {
+				err := pub.CheckPublishPermission(ctx)
+				if errors.Is(err, publisher.ErrTopicNotFound) {
+					return fmt.Errorf("topic %q does not exist, create it with 'substreams-sink-pubsub tools topic create %s' or pass '--create-topic-if-missing'", topicName, topicName)
+				}
+				if errors.Is(err, publisher.ErrPublishPermissionDenied) {
+					return fmt.Errorf("the credentials cannot publish to topic %q, grant them the 'roles/pubsub.publisher' role on it, e.g. 'gcloud pubsub topics add-iam-policy-binding %s --member=serviceAccount:<email> --role=roles/pubsub.publisher'", topicName, topicName)
+				}
+				return err
+			},
+		},
+	}
+
+	if !pub.MessageOrdering() {
+		zlog.Info("preflight check skipped, messages carry no ordering key", zap.String("check", "message ordering"))
+		return checks
+	}
+
+	return append(checks, preflightCheck{
+		name: "message ordering",
+		run: func(ctx context.Context) error {
+			err := pub.CheckMessageOrdering(ctx)
+			if errors.Is(err, publisher.ErrMessageOrderingDisabled) {
+				return fmt.Errorf("the topic publishes messages in ordering key order but %w, they would be delivered out of order, recreate the subscriptions with 'substreams-sink-pubsub tools subscription create <subscription> %s --ordering'", err, topicName)
+			}
+			return err
+		},
+	})
+}
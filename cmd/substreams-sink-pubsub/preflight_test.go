@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+func TestPreflightPubSub(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client, err := pubsub.NewClient(ctx, "project", option.WithGRPCConn(conn))
+	require.NoError(t, err)
+
+	pub := publisher.NewPubSub(client, client.Topic("topic"))
+
+	err = runPreflightChecks(ctx, pubSubPreflightChecks(pub, "topic", false))
+	require.ErrorContains(t, err, `preflight: topic "topic" does not exist`)
+
+	require.NoError(t, runPreflightChecks(ctx, pubSubPreflightChecks(pub, "topic", true)))
+
+	_, err = client.CreateSubscription(ctx, "unordered", pubsub.SubscriptionConfig{Topic: client.Topic("topic")})
+	require.NoError(t, err)
+
+	// Without ordering keys, the subscriptions' ordering doesn't matter
+	require.NoError(t, runPreflightChecks(ctx, pubSubPreflightChecks(pub, "topic", false)))
+
+	ordered := client.Topic("topic")
+	ordered.EnableMessageOrdering = true
+	err = runPreflightChecks(ctx, pubSubPreflightChecks(publisher.NewPubSub(client, ordered), "topic", false))
+	require.ErrorContains(t, err, `preflight: the topic publishes messages in ordering key order but message ordering disabled on subscriptions ["unordered"]`)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	. "github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
+	"go.uber.org/zap"
+
+	spubsub "github.com/streamingfast/substreams-sink-pubsub"
+)
+
+// replayIDSuffix is appended to the ID of replayed messages, so that destinations
+// de-duplicating by ID don't drop them as copies of the originally published ones.
+const replayIDSuffix = "-replay"
+
+var replayCmd = Command(replayRunE,
+	"replay [<manifest-path> <module-name> <topic-name> <start>:<stop>]",
+	"Re-publish a block range to a topic without touching the sink's cursor",
+	RangeArgs(0, 4),
+	Flags(func(flags *pflag.FlagSet) {
+		addSinkFlags(flags)
+
+		flags.String("replay-cursor-path", "", "Path of the replay's own cursor, allowing an interrupted replay to resume, a temporary directory deleted once the replay completes if empty")
+	}),
+	Description(`
+		Re-publishes the messages of a fixed block range to a topic, usually a side topic, for
+		example after a consumer bug. Every message has the 'Replay' attribute set to 'true' and
+		its ID suffixed with '-replay', so that NATS JetStream doesn't de-duplicate it against the
+		original message, while the blocks published again by a resumed replay still are.
+
+		The replay keeps its own cursor, the sink's cursor in '--cursor_path' is never read nor
+		written and the command refuses to run if '--replay-cursor-path' points to it. The replay
+		cursor is ephemeral unless '--replay-cursor-path' is set, so an interrupted replay
+		restarts from <start>.
+
+		The arguments and flags are the ones of the 'sink' command, including '--config', except
+		that <start>:<stop> is required and '--leader-election' is rejected. The checkpoint flags
+		apply to the replay cursor, and '--health-listen-addr' serves the replay's own probes so it
+		must differ from the address of a sink running on the same host.
+	`),
+	ExamplePrefixed("substreams-sink-pubsub replay", `
+		# Re-publish the messages of blocks 18,000,000 to 18,009,999 to a side topic
+		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "replay-topic" 18000000:18010000
+		# Same, resumable if interrupted
+		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "replay-topic" 18000000:18010000 --replay-cursor-path ./replay-state
+	`),
+)
+
+func replayRunE(cmd *cobra.Command, args []string) error {
+	config, args, err := loadSinkConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 4 || args[0] == "" || args[1] == "" || args[2] == "" {
+		return fmt.Errorf("<manifest-path>, <module-name>, <topic-name> and <start>:<stop> are required, as arguments or in the '--config' file")
+	}
+
+	manifestPath, module, topicName, blockRange := extractInjectArgs(cmd, args)
+	if _, err := parseBlockRange(blockRange); err != nil {
+		return err
+	}
+
+	if sflags.MustGetString(cmd, "leader-election") != "" {
+		return fmt.Errorf("'--leader-election' is not supported by replay, a replay runs as a single process with its own cursor and must not compete for the sink's lease")
+	}
+
+	replayCursorPath := sflags.MustGetString(cmd, "replay-cursor-path")
+	if replayCursorPath == "" {
+		var err error
+		replayCursorPath, err = os.MkdirTemp("", "substreams-sink-pubsub-replay-")
+		if err != nil {
+			return fmt.Errorf("creating replay cursor directory: %w", err)
+		}
+		defer os.RemoveAll(replayCursorPath)
+	} else {
+		same, err := samePath(sflags.MustGetString(cmd, "cursor_path"), replayCursorPath)
+		if err != nil {
+			return err
+		}
+		if same {
+			return fmt.Errorf("refusing to replay with the sink's cursor path %q, '--replay-cursor-path' must point to another directory", replayCursorPath)
+		}
+	}
+
+	zlog.Info("replaying block range", zap.String("block_range", blockRange), zap.String("topic", topicName), zap.String("replay_cursor_path", replayCursorPath))
+
+	attributes := map[string]string{"Replay": "true"}
+	for key, value := range config.Attributes {
+		if key != "Replay" {
+			attributes[key] = value
+		}
+	}
+
+	opts, err := configOptions(config, sflags.MustGetString(cmd, "envelope"))
+	if err != nil {
+		return err
+	}
+
+	return runSink(cmd, manifestPath, module, topicName, blockRange, replayCursorPath, attributes, append(opts, spubsub.WithMessageIDSuffix(replayIDSuffix))...)
+}
+
+// samePath returns true if both paths point to the same directory, resolving symbolic links
+// when the directories exist.
+func samePath(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, fmt.Errorf("resolving %q: %w", a, err)
+	}
+
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, fmt.Errorf("resolving %q: %w", b, err)
+	}
+
+	if absA == absB {
+		return true, nil
+	}
+
+	infoA, errA := os.Stat(absA)
+	infoB, errB := os.Stat(absB)
+	if errA != nil || errB != nil {
+		return false, nil
+	}
+
+	return os.SameFile(infoA, infoB), nil
+}
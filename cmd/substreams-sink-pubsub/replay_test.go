@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayRejectsLeaderElection(t *testing.T) {
+	cmd := &cobra.Command{}
+	addSinkFlags(cmd.Flags())
+	cmd.Flags().String("replay-cursor-path", "", "")
+	require.NoError(t, cmd.ParseFlags([]string{"--leader-election", "gs://bucket/lease"}))
+
+	err := replayRunE(cmd, []string{"./substreams.yaml", "map_transfers", "replay-topic", "0:10"})
+	require.ErrorContains(t, err, "'--leader-election' is not supported by replay")
+}
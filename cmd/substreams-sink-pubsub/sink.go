@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	pubsub "cloud.google.com/go/pubsub"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	. "github.com/streamingfast/cli"
@@ -11,28 +17,25 @@ import (
 	"github.com/streamingfast/shutter"
 	sink "github.com/streamingfast/substreams-sink"
 	"github.com/streamingfast/substreams/manifest"
+	"go.uber.org/zap"
 
 	spubsub "github.com/streamingfast/substreams-sink-pubsub"
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
 )
 
 var sinkCmd = Command(sinkRunE,
-	"sink <manifest-path> <module-name> <topic-name> [<block-range>]",
+	"sink [<manifest-path> <module-name> <topic-name> [<block-range>]]",
 	"Substreams Pubsub sinking",
-	RangeArgs(3, 4),
-	Flags(func(flags *pflag.FlagSet) {
-		sink.AddFlagsToSet(flags)
-
-		flags.String("cursor_path", "./state", "Sink cursor's path")
-		flags.String("project", "", "Google Cloud Project ID")
-		flags.StringP("endpoint", "e", "", "Substreams gRPC endpoint (e.g. 'mainnet.eth.streamingfast.io:443')")
-	}),
+	RangeArgs(0, 4),
+	Flags(addSinkFlags),
 	Description(`
 		Publishs block data on a google PubSub from a Substreams output.
 
 		The required arguments are:
 		- <manifest-path>: URL or local path to a '.yaml' file (e.g. './examples/simple/substreams.yaml').
 		- <module-name>: The module name returning publish instructions in the substreams.
-		- <topic-name>: The PubSub topic name to publish the messages to.
+		- <topic-name>: The PubSub topic name to publish the messages to (or the Kafka topic or Redis stream when using '--destination kafka://...' or '--destination redis://...').
+		                For NATS, it's the subject and for AMQP the exchange, both may contain '{{BlockNumber}}', '{{BlockID}}', '{{OrderingKey}}' or '{{<attribute>}}' placeholders (e.g. 'chain.{{BlockNumber}}').
 
 		The optional arguments are:
 		- <start>:<stop>: The range of block to sync, if not provided, will sync from the module's initial block and then forever.
@@ -42,68 +45,193 @@ var sinkCmd = Command(sinkRunE,
 						  * <start>:<stop>: (sync from <start> to <stop>)
 
 		If <start>:<stop> is not provided, assumes the whole chain.
+
+		Before streaming, the sink checks that the module outputs 'sf.substreams.sink.pubsub.v1.Publish'
+		and, with the PubSub destination, that the topic exists (see '--create-topic-if-missing')
+		and that the credentials have the 'pubsub.topics.publish' permission on it.
+
+		With '--config', the arguments and flags can be set in a YAML or TOML file instead, the
+		arguments are then read from the file when none are passed. Values passed on the command
+		line or through 'PUBSUB_SINK_*' environment variables take precedence over the file. See
+		'tools config validate --help' for the file's format.
 	`),
 	ExamplePrefixed("substreams-sink-pubsub sink", `
 		# Publish block data messages produced by map_clocks for the whole chain
 		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "topic" --project "1"
 		# Publish block data messages produced by map_clocks for a specific range of blocks
 		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "topic" 0:1000 --project "1"
+		# Publish block data messages produced by map_clocks to a Kafka topic
+		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "topic" --destination kafka://localhost:9092
+		# Validate the messages produced by map_clocks over a range without publishing them
+		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "topic" 0:1000 --dry-run
+		# Print block data messages produced by map_clocks as JSON lines, e.g. to pipe into jq
+		./examples/simple/substreams.yaml map_clocks "topic" 0:100 --destination stdout
+		# Push block data messages produced by map_clocks to a webhook, one request per block
+		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "topic" --destination https://example.com/hook --webhook-per-block
+		# Publish block data messages produced by map_clocks to NATS JetStream, one subject per block
+		-e mainnet.eth.streamingfast.io:443 ./examples/simple/substreams.yaml map_clocks "chain.{{BlockNumber}}" --destination nats://localhost:4222
+		# Run with the arguments and flags of a configuration file
+		--config sink.yaml
 	`),
 )
 
+// publishOutputType is the output type the sink's module must have.
+const publishOutputType = "sf.substreams.sink.pubsub.v1.Publish"
+
+// addSinkFlags adds the flags of the commands streaming a module's output to a destination.
+func addSinkFlags(flags *pflag.FlagSet) {
+	sink.AddFlagsToSet(flags)
+
+	flags.String("config", "", "Path of a YAML ('.yaml', '.yml') or TOML ('.toml') configuration file setting the arguments and flags, see 'tools config validate --help' for its format")
+	flags.String("cursor_path", "./state", "Sink cursor's path")
+	flags.Int("checkpoint-blocks", 0, "Save the cursor once this many blocks with messages were handled since the last save, disabled if 0, see '--checkpoint-interval'")
+	flags.Duration("checkpoint-interval", 0, "Save the cursor once this much time elapsed since the last save, disabled if 0, also checked while no block arrives, the cursor is saved after every block with messages when both '--checkpoint-blocks' and '--checkpoint-interval' are disabled and always after an undo signal and on shutdown, blocks handled since the last save are published again after a crash")
+	addPubSubFlags(flags)
+	addLeaderElectionFlags(flags)
+	flags.String("envelope", "message", "How the module's messages are published, 'message' publishes each one as a PubSub message, 'block' publishes all the messages of a block in a single 'sf.substreams.sink.pubsub.v1.BlockEnvelope' message carrying the block's clock, cursor and step, split in chunks when larger than the PubSub limit")
+	flags.Bool("block-end-marker", false, "Once the messages of a block are acknowledged, publish a 'Step=BlockEnd' marker message carrying the block number, message count, content hash and cursor, so consumers can commit a whole block at once, nothing is published for blocks without messages")
+	flags.Duration("heartbeat-interval", 0, "When no message was published for this long, publish a 'Step=Heartbeat' message carrying the latest handled block's number, ID, timestamp and cursor, so consumers can tell a stalled sink from a module emitting nothing, disabled if 0")
+	flags.Bool("create-topic-if-missing", false, "Create the PubSub topic on startup if it doesn't exist, instead of failing the preflight checks")
+	flags.Bool("dry-run", false, "Process the stream without publishing any message nor reading or writing the cursor, messages are validated against PubSub limits and a summary is printed at the end")
+	flags.String("destination", "pubsub", "Where messages are published, 'pubsub' for Google Cloud PubSub, 'kafka://<broker>[,<broker>...]' for a Kafka cluster, 'nats://<server>[,<server>...]' for NATS JetStream, 'redis://<host>:<port>[/<db>][?maxlen=<entries>]' for a Redis stream, 'amqp://<host>:<port>[/<vhost>][?routing_key=<template>&undo_routing_key=<template>]' for an AMQP broker, an 'http[s]://' webhook URL, 'file://<directory>[?rotate=<blocks>&gzip=true]' for JSONL files or 'stdout', see <topic-name> for how the topic is interpreted")
+	flags.StringP("endpoint", "e", "", "Substreams gRPC endpoint (e.g. 'mainnet.eth.streamingfast.io:443')")
+	flags.String("health-listen-addr", "", "If non-empty, serve the '/healthz' liveness and '/readyz' readiness probes on this address (e.g. ':8080')")
+	flags.Duration("health-block-timeout", 0, "Liveness fails when no block was handled for this long, including since startup, disabled if 0")
+	flags.Int("health-max-publish-errors", 0, "Liveness fails when more than this many messages failed to publish since the last published block, disabled if 0")
+	flags.Duration("drain-timeout", spubsub.DefaultDrainTimeout, "On SIGINT or SIGTERM, time given to the block being handled and to in-flight messages to be acknowledged before exiting, a second signal exits immediately")
+
+	flags.Bool("webhook-per-block", false, "With a webhook destination, POST all the messages of a block in a single JSON request instead of one request per message")
+	flags.Duration("webhook-timeout", 30*time.Second, "With a webhook destination, timeout of each request attempt")
+	flags.Int("webhook-max-retries", 10, "With a webhook destination, number of times a request failing with a network error, 429 or 5xx status is retried before the sink stops")
+	flags.Int("webhook-concurrency", 8, "With a webhook destination, maximum number of requests in flight, without '--webhook-per-block' the messages of a block may be delivered out of order unless 1")
+	flags.String("webhook-secret-envvar", "SUBSTREAMS_SINK_WEBHOOK_SECRET", "With a webhook destination, name of the environment variable holding the secret used to sign requests with HMAC-SHA256 of '<timestamp>.<body>' (sent in the 'X-Substreams-Signature-256' header, with the Unix timestamp in 'X-Substreams-Timestamp', receivers should reject timestamps more than 5 minutes old), requests are not signed if empty")
+}
+
 func sinkRunE(cmd *cobra.Command, args []string) error {
-	app := shutter.New()
-	ctx := cmd.Context()
+	config, args, err := loadSinkConfig(cmd, args)
+	if err != nil {
+		return err
+	}
 
-	manifestPath, module, topicName, blockRange := extractInjectArgs(cmd, args)
-	endpoint := sflags.MustGetString(cmd, "endpoint")
-	cursorPath := sflags.MustGetString(cmd, "cursor_path")
-	projectID := sflags.MustGetString(cmd, "project")
-	if projectID == "" {
-		projectID = pubsub.DetectProjectID
+	if len(args) < 3 || args[0] == "" || args[1] == "" || args[2] == "" {
+		return fmt.Errorf("<manifest-path>, <module-name> and <topic-name> are required, as arguments or in the '--config' file")
 	}
 
-	client, err := pubsub.NewClient(ctx, projectID)
+	manifestPath, module, topicName, blockRange := extractInjectArgs(cmd, args)
+
+	opts, err := configOptions(config, sflags.MustGetString(cmd, "envelope"))
 	if err != nil {
-		return fmt.Errorf("creating pubsub client: %w", err)
+		return err
 	}
 
-	topic := client.Topic(topicName)
+	return runSink(cmd, manifestPath, module, topicName, blockRange, sflags.MustGetString(cmd, "cursor_path"), config.Attributes, opts...)
+}
 
-	// FIXME: This is now duplicated across sinkers (this one and Substreams Sink SQL). It should have
-	// definitely be added in sink.NewFromViper directly so that it's shared across all sinkers.
-	// I was too lazy for now to do it, sorry about that.
-	if endpoint == "" {
-		network := sflags.MustGetString(cmd, "network")
-		if network == "" {
-			reader, err := manifest.NewReader(manifestPath)
-			if err != nil {
-				return fmt.Errorf("setup manifest reader: %w", err)
-			}
-			pkgBundle, err := reader.Read()
-			if err != nil {
-				return fmt.Errorf("read manifest: %w", err)
-			}
-			network = pkgBundle.Package.Network
-		}
+// runSink streams the module's output over blockRange to the destination, saving its progress
+// in cursorPath. The attributes are added to every message, and the options applied after the
+// ones set from the flags.
+func runSink(cmd *cobra.Command, manifestPath, module, topicName, blockRange, cursorPath string, attributes map[string]string, extraOpts ...spubsub.Option) error {
+	app := shutter.New()
+	ctx := cmd.Context()
+
+	dryRun := sflags.MustGetBool(cmd, "dry-run")
+
+	envelope := sflags.MustGetString(cmd, "envelope")
+	if envelope != "message" && envelope != "block" {
+		return fmt.Errorf("invalid '--envelope' %q, expected 'message' or 'block'", envelope)
+	}
+
+	var pub publisher.Publisher
+	var dryRunPublisher *publisher.DryRun
+	if dryRun {
+		dryRunPublisher = publisher.NewDryRun()
+		pub = dryRunPublisher
+	} else {
 		var err error
-		endpoint, err = manifest.ExtractNetworkEndpoint(network, sflags.MustGetString(cmd, "endpoint"), zlog)
-		if err != nil {
-			return err
+		if pub, err = newPublisher(ctx, cmd, topicName); err != nil {
+			return fmt.Errorf("creating publisher: %w", err)
 		}
 	}
 
+	// The sink closes the publisher once it runs, until then it's closed on return
+	closePublisher := true
+	defer func() {
+		if closePublisher {
+			pub.Close()
+		}
+	}()
+
+	endpoint, err := resolveEndpoint(cmd, manifestPath)
+	if err != nil {
+		return err
+	}
+
+	var health *spubsub.Health
+	var sinkerOpts []sink.Option
+	healthAddr := sflags.MustGetString(cmd, "health-listen-addr")
+	if healthAddr != "" {
+		checker, _ := pub.(publisher.Checker)
+		health = spubsub.NewHealth(sflags.MustGetDuration(cmd, "health-block-timeout"), sflags.MustGetInt(cmd, "health-max-publish-errors"), checker)
+		sinkerOpts = append(sinkerOpts, sink.WithRetryBackOff(health.RetryBackOff()))
+	}
+
 	sinker, err := sink.NewFromViper(
 		cmd,
-		"sf.substreams.sink.pubsub.v1.Publish",
+		publishOutputType,
 		endpoint, manifestPath, module, blockRange,
 		zlog, tracer,
+		sinkerOpts...,
 	)
 	if err != nil {
 		return fmt.Errorf("unable to setup sinker: %w", err)
 	}
 
-	s := spubsub.NewSink(sinker, zlog, cursorPath, client, topic)
+	if err := preflightChecks(ctx, cmd, pub, topicName); err != nil {
+		return err
+	}
+
+	opts := []spubsub.Option{
+		spubsub.WithSinker(sinker),
+		spubsub.WithPublisher(pub),
+		spubsub.WithLogger(zlog),
+		spubsub.WithCursorPath(cursorPath),
+		spubsub.WithAttributes(attributes),
+		spubsub.WithHeartbeatInterval(sflags.MustGetDuration(cmd, "heartbeat-interval")),
+		spubsub.WithCheckpoint(sflags.MustGetInt(cmd, "checkpoint-blocks"), sflags.MustGetDuration(cmd, "checkpoint-interval")),
+		spubsub.WithDrainTimeout(sflags.MustGetDuration(cmd, "drain-timeout")),
+	}
+	if dryRun {
+		opts = append(opts, spubsub.WithDryRun())
+	}
+	if envelope == "block" {
+		opts = append(opts, spubsub.WithBlockEnvelope())
+	}
+	if sflags.MustGetBool(cmd, "block-end-marker") {
+		opts = append(opts, spubsub.WithBlockEndMarker())
+	}
+
+	s, err := spubsub.New(append(opts, extraOpts...)...)
+	if err != nil {
+		return fmt.Errorf("creating sink: %w", err)
+	}
+
+	elector, err := newElector(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if elector != nil {
+		defer elector.Close()
+	}
+
+	if health != nil {
+		s.SetHealth(health)
+
+		server, err := serveHealth(healthAddr, health)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+	}
 
 	s.OnTerminating(func(err error) {
 		if err != nil {
@@ -116,11 +244,134 @@ func sinkRunE(cmd *cobra.Command, args []string) error {
 		s.Shutdown(err)
 	})
 
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	campaignCtx, stopCampaign := context.WithCancel(ctx)
+	defer stopCampaign()
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case sig := <-signals:
+			zlog.Info("received signal, draining", zap.Stringer("signal", sig))
+			stopCampaign()
+			s.Drain()
+		}
+
+		select {
+		case <-done:
+		case sig := <-signals:
+			zlog.Warn("received second signal, exiting without draining", zap.Stringer("signal", sig))
+			os.Exit(1)
+		}
+	}()
+
+	lostLeadership := make(chan error, 1)
+	if elector != nil {
+		health.SetStandby(true)
+		zlog.Info("waiting for leadership")
+
+		token, err := elector.Campaign(campaignCtx)
+		if err != nil {
+			if campaignCtx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := spubsub.ClaimFencingToken(cursorPath, token); err != nil {
+			return err
+		}
+		s.SetFencingToken(token)
+		health.SetStandby(false)
+
+		holdCtx, stopHolding := context.WithCancel(context.WithoutCancel(ctx))
+		go elector.Hold(holdCtx, func(err error) {
+			zlog.Error("stopping, leadership lost", zap.Error(err))
+			lostLeadership <- err
+			s.Drain()
+		})
+
+		defer func() {
+			stopHolding()
+
+			resignCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := elector.Resign(resignCtx); err != nil {
+				zlog.Warn("resigning leadership", zap.Error(err))
+			}
+		}()
+	}
+
+	closePublisher = false
 	s.Run(ctx)
 
+	select {
+	case err := <-lostLeadership:
+		return err
+	default:
+	}
+
+	if dryRunPublisher != nil {
+		if err := dryRunPublisher.WriteSummary(os.Stdout); err != nil {
+			return fmt.Errorf("writing dry run summary: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// serveHealth serves the health probes on addr in the background.
+func serveHealth(addr string, health *spubsub.Health) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening for health probes on %q: %w", addr, err)
+	}
+
+	server := &http.Server{Handler: health.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			zlog.Warn("health server failed", zap.Error(err))
+		}
+	}()
+
+	zlog.Info("serving health probes", zap.String("addr", listener.Addr().String()))
+	return server, nil
+}
+
+// resolveEndpoint returns the '--endpoint' flag or, if unset, the endpoint of the '--network'
+// flag or of the network declared by the manifest.
+func resolveEndpoint(cmd *cobra.Command, manifestPath string) (string, error) {
+	// FIXME: This is now duplicated across sinkers (this one and Substreams Sink SQL). It should have
+	// definitely be added in sink.NewFromViper directly so that it's shared across all sinkers.
+	// I was too lazy for now to do it, sorry about that.
+	endpoint := sflags.MustGetString(cmd, "endpoint")
+	if endpoint != "" {
+		return endpoint, nil
+	}
+
+	network := sflags.MustGetString(cmd, "network")
+	if network == "" {
+		reader, err := manifest.NewReader(manifestPath)
+		if err != nil {
+			return "", fmt.Errorf("setup manifest reader: %w", err)
+		}
+		pkgBundle, err := reader.Read()
+		if err != nil {
+			return "", fmt.Errorf("read manifest: %w", err)
+		}
+		network = pkgBundle.Package.Network
+	}
+
+	return manifest.ExtractNetworkEndpoint(network, endpoint, zlog)
+}
+
 func extractInjectArgs(_ *cobra.Command, args []string) (manifestPath, moduleName, topicName, blockRange string) {
 	manifestPath = args[0]
 	moduleName = args[1]
@@ -0,0 +1,14 @@
+package main
+
+import (
+	. "github.com/streamingfast/cli"
+)
+
+var toolsGroup = Group("tools", "Operator and developer tools",
+	toolsCursorGroup,
+	toolsTopicGroup,
+	toolsSubscriptionGroup,
+	toolsTailCmd,
+	toolsVerifyCmd,
+	toolsConfigGroup,
+)
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/streamingfast/bstream"
+	. "github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
+	sink "github.com/streamingfast/substreams-sink"
+
+	spubsub "github.com/streamingfast/substreams-sink-pubsub"
+)
+
+var toolsCursorGroup = Group("cursor", "Inspect and change the sink's cursor",
+	PersistentFlags(func(flags *pflag.FlagSet) {
+		flags.String("cursor_path", "./state", "Sink cursor's path")
+	}),
+
+	Command(toolsCursorShowE,
+		"show",
+		"Decode and print the saved cursor",
+		NoArgs(),
+	),
+
+	Command(toolsCursorSetE,
+		"set <block-num>|<cursor>",
+		"Replace the saved cursor",
+		ExactArgs(1),
+		Flags(func(flags *pflag.FlagSet) {
+			flags.String("block-id", "", "When setting from a block number, the ID of the block, recommended so the Substreams endpoint can validate it's on the canonical chain")
+			flags.BoolP("yes", "y", false, "Do not ask for confirmation before rewinding the cursor")
+		}),
+		Description(`
+			Replace the saved cursor, the sink must be stopped while doing so.
+
+			The argument is either a raw cursor, as printed by 'tools cursor show', or a block number.
+			A cursor created from a block number points to that block as a final block, the sink
+			restarts at the following block.
+
+			Confirmation is asked before moving the cursor to an earlier block, which re-publishes
+			the blocks in between.
+		`),
+	),
+
+	Command(toolsCursorResetE,
+		"reset",
+		"Delete the saved cursor, the sink restarts from the beginning of its block range",
+		NoArgs(),
+		Flags(func(flags *pflag.FlagSet) {
+			flags.BoolP("yes", "y", false, "Do not ask for confirmation")
+		}),
+	),
+
+	Command(toolsCursorHistoryE,
+		"history",
+		"List the changes made to the cursor with 'tools cursor set' and 'tools cursor reset'",
+		NoArgs(),
+	),
+)
+
+func toolsCursorShowE(cmd *cobra.Command, args []string) error {
+	cursor, err := spubsub.LoadCursor(sflags.MustGetString(cmd, "cursor_path"))
+	if err != nil {
+		return fmt.Errorf("loading cursor: %w", err)
+	}
+
+	if cursor.IsBlank() {
+		fmt.Println("No cursor saved, the sink starts from the beginning of its block range")
+		return nil
+	}
+
+	return printCursor(cursor)
+}
+
+func toolsCursorSetE(cmd *cobra.Command, args []string) error {
+	cursorPath := sflags.MustGetString(cmd, "cursor_path")
+
+	current, err := spubsub.LoadCursor(cursorPath)
+	if err != nil {
+		return fmt.Errorf("loading cursor: %w", err)
+	}
+
+	cursor, err := cursorFromArg(args[0], sflags.MustGetString(cmd, "block-id"))
+	if err != nil {
+		return err
+	}
+
+	if !current.IsBlank() && cursor.Block().Num() < current.Block().Num() {
+		label := fmt.Sprintf("Rewind cursor from %s to %s, re-publishing the blocks in between", current.Block(), cursor.Block())
+		if err := confirm(cmd, label); err != nil {
+			return err
+		}
+	}
+
+	if err := spubsub.SaveCursor(cursorPath, cursor); err != nil {
+		return fmt.Errorf("saving cursor: %w", err)
+	}
+
+	if err := spubsub.AppendCursorHistory(cursorPath, &spubsub.CursorHistoryEntry{Time: time.Now(), Action: "set", Previous: current.String(), Cursor: cursor.String()}); err != nil {
+		return err
+	}
+
+	fmt.Println("Cursor set")
+	return printCursor(cursor)
+}
+
+func toolsCursorResetE(cmd *cobra.Command, args []string) error {
+	cursorPath := sflags.MustGetString(cmd, "cursor_path")
+
+	current, err := spubsub.LoadCursor(cursorPath)
+	if err != nil {
+		return fmt.Errorf("loading cursor: %w", err)
+	}
+
+	if current.IsBlank() {
+		fmt.Println("No cursor saved, nothing to reset")
+		return nil
+	}
+
+	if err := confirm(cmd, fmt.Sprintf("Delete cursor at %s, the sink restarts from the beginning of its block range", current.Block())); err != nil {
+		return err
+	}
+
+	if err := spubsub.DeleteCursor(cursorPath); err != nil {
+		return err
+	}
+
+	if err := spubsub.AppendCursorHistory(cursorPath, &spubsub.CursorHistoryEntry{Time: time.Now(), Action: "reset", Previous: current.String()}); err != nil {
+		return err
+	}
+
+	fmt.Println("Cursor reset")
+	return nil
+}
+
+func toolsCursorHistoryE(cmd *cobra.Command, args []string) error {
+	entries, err := spubsub.ReadCursorHistory(sflags.MustGetString(cmd, "cursor_path"))
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No cursor change recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tFROM\tTO")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Time.Format(time.RFC3339), entry.Action, cursorBlockString(entry.Previous), cursorBlockString(entry.Cursor))
+	}
+
+	return w.Flush()
+}
+
+// cursorFromArg parses arg as a block number or as a raw cursor.
+func cursorFromArg(arg string, blockID string) (*sink.Cursor, error) {
+	blockNum, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		cursor, err := sink.NewCursor(arg)
+		if err != nil || cursor.IsBlank() {
+			return nil, fmt.Errorf("argument %q is neither a block number nor a valid cursor", arg)
+		}
+
+		return cursor, nil
+	}
+
+	block := bstream.NewBlockRef(blockID, blockNum)
+
+	return &sink.Cursor{Cursor: &bstream.Cursor{
+		Step:      bstream.StepNewIrreversible,
+		Block:     block,
+		LIB:       block,
+		HeadBlock: block,
+	}}, nil
+}
+
+func printCursor(cursor *sink.Cursor) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Block\t%s\n", cursor.Block())
+	fmt.Fprintf(w, "Step\t%s\n", cursor.Step)
+	fmt.Fprintf(w, "LIB\t%s\n", cursor.LIB)
+	fmt.Fprintf(w, "Head block\t%s\n", cursor.HeadBlock)
+	fmt.Fprintf(w, "Cursor\t%s\n", cursor.String())
+
+	return w.Flush()
+}
+
+func cursorBlockString(raw string) string {
+	if raw == "" {
+		return "-"
+	}
+
+	cursor, err := sink.NewCursor(raw)
+	if err != nil {
+		return "<invalid>"
+	}
+
+	return cursor.Block().String()
+}
+
+// confirm asks the user to confirm the action described by label unless '--yes' is set,
+// returning an error if the action is declined or if confirmation cannot be asked.
+func confirm(cmd *cobra.Command, label string) error {
+	if sflags.MustGetBool(cmd, "yes") {
+		return nil
+	}
+
+	answer, wasAnswered := PromptConfirm(label)
+	if !wasAnswered {
+		return fmt.Errorf("confirmation required but not running in a terminal, use '--yes' to confirm")
+	}
+
+	if !answer {
+		return fmt.Errorf("aborted")
+	}
+
+	return nil
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	. "github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
+	sink "github.com/streamingfast/substreams-sink"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+var toolsTailCmd = Command(toolsTailE,
+	"tail [<subscription-name>]",
+	"Consume a PubSub subscription and print the messages published by the sink",
+	RangeArgs(0, 1),
+	Flags(func(flags *pflag.FlagSet) {
+		addPubSubFlags(flags)
+		flags.String("topic", "", "Create a temporary subscription to this topic, deleted on exit, instead of consuming <subscription-name>")
+		flags.String("output", "table", "Output format, either 'table' or 'json' (one message per line)")
+		flags.Bool("ack", true, "Acknowledge printed messages, when false they are nacked and redelivered to other consumers of the subscription")
+		flags.Int("limit", 0, "Stop after printing this many messages, unlimited if 0")
+		flags.Int("data-limit", 80, "In table output, truncate data to this many characters, unlimited if 0")
+	}),
+	Description(`
+		Consume a PubSub subscription and print the messages published by the sink.
+
+		The 'Cursor' attribute is decoded into the block it points to and undo messages are
+		highlighted along with the last valid block. The step is read from the 'Step' attribute,
+		'New' when it's missing. Data compressed by the sink ('ContentEncoding=gzip') is
+		decompressed, then printed as text when it's valid UTF-8 and as hex otherwise.
+
+		Messages split in chunks ('Chunk' and 'Chunks' attributes) are printed once all their
+		chunks are received, with their data reassembled. Chunks of the same message are the ones
+		whose attributes, other than 'Chunk', are identical. Block envelope chunks
+		('EnvelopeChunk' and 'EnvelopeChunks' attributes) are reassembled the same way, after
+		the chunks they were split into.
+
+		Either consume an existing subscription, acknowledging messages by default, or pass
+		'--topic' to create a temporary subscription receiving messages published from now on.
+	`),
+	ExamplePrefixed("substreams-sink-pubsub tools tail", `
+		# Print messages published to dev-topic from now on
+		--topic dev-topic --project acme
+		# Peek at an existing subscription without consuming its messages, as JSON
+		dev-topic-sub --project acme --ack=false --output json --limit 10
+	`),
+)
+
+// tailMessage is a PubSub message decoded for printing by 'tools tail'.
+type tailMessage struct {
+	ID              string            `json:"id"`
+	PublishTime     time.Time         `json:"publish_time"`
+	BlockNumber     uint64            `json:"block_number,omitempty"`
+	BlockID         string            `json:"block_id,omitempty"`
+	Step            string            `json:"step"`
+	Undo            bool              `json:"undo,omitempty"`
+	LastValidBlock  string            `json:"last_valid_block,omitempty"`
+	OrderingKey     string            `json:"ordering_key,omitempty"`
+	Attributes      map[string]string `json:"attributes,omitempty"`
+	Data            string            `json:"data,omitempty"`
+	DataEncoding    string            `json:"data_encoding,omitempty"`
+	DeliveryAttempt *int              `json:"delivery_attempt,omitempty"`
+}
+
+func toolsTailE(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	output := sflags.MustGetString(cmd, "output")
+	if output != "table" && output != "json" {
+		return fmt.Errorf("invalid '--output' %q, valid values are 'table' or 'json'", output)
+	}
+
+	topicName := sflags.MustGetString(cmd, "topic")
+	if (len(args) == 0) == (topicName == "") {
+		return fmt.Errorf("either <subscription-name> or '--topic' must be set, but not both")
+	}
+
+	client, err := newPubSubClient(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var subscription *pubsub.Subscription
+	if topicName != "" {
+		subscription, err = createTemporarySubscription(ctx, client.Client, topicName)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// The command's context is likely cancelled at this point
+			if err := subscription.Delete(context.Background()); err != nil {
+				zlog.Warn("unable to delete temporary subscription", zap.Stringer("subscription", subscription), zap.Error(err))
+			}
+		}()
+	} else {
+		subscription = client.Subscription(args[0])
+	}
+
+	// Messages are printed in the order they are received
+	subscription.ReceiveSettings.NumGoroutines = 1
+	subscription.ReceiveSettings.MaxOutstandingMessages = 100
+
+	ack := sflags.MustGetBool(cmd, "ack")
+	limit := sflags.MustGetInt(cmd, "limit")
+	dataLimit := sflags.MustGetInt(cmd, "data-limit")
+
+	receiveCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	highlight := isTerminal(os.Stdout)
+	if output == "table" {
+		fmt.Printf(tailTableFormat+"\n", "PUBLISHED", "BLOCK", "STEP", "ATTRIBUTES / DATA")
+	}
+
+	var lock sync.Mutex
+	var printed int
+	// Nacked messages are redelivered, they are printed once
+	seen := map[string]bool{}
+	chunks := newTailChunks("Chunk", "Chunks")
+	envelopeChunks := newTailChunks("EnvelopeChunk", "EnvelopeChunks")
+	err = subscription.Receive(receiveCtx, func(_ context.Context, message *pubsub.Message) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if (limit > 0 && printed >= limit) || seen[message.ID] {
+			message.Nack()
+			return
+		}
+
+		if ack {
+			message.Ack()
+		} else {
+			seen[message.ID] = true
+			message.Nack()
+		}
+
+		complete := chunks.add(message)
+		if complete != nil {
+			complete = envelopeChunks.add(complete)
+		}
+		if complete == nil {
+			return
+		}
+
+		decoded := decodeTailMessage(complete)
+		if output == "json" {
+			line, err := json.Marshal(decoded)
+			if err != nil {
+				zlog.Warn("unable to encode message", zap.String("id", message.ID), zap.Error(err))
+			} else {
+				fmt.Println(string(line))
+			}
+		} else {
+			fmt.Println(decoded.tableRow(dataLimit, highlight))
+		}
+
+		printed++
+		if limit > 0 && printed >= limit {
+			stop()
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("receiving from subscription %q: %w", subscription.ID(), err)
+	}
+
+	return nil
+}
+
+func createTemporarySubscription(ctx context.Context, client *pubsub.Client, topicName string) (*pubsub.Subscription, error) {
+	id := fmt.Sprintf("%s-tail-%d", topicName, time.Now().UnixNano())
+
+	subscription, err := client.CreateSubscription(ctx, id, pubsub.SubscriptionConfig{
+		Topic:                 client.Topic(topicName),
+		EnableMessageOrdering: true,
+		// Removed by PubSub if the command is killed before deleting it
+		ExpirationPolicy: 24 * time.Hour,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary subscription to topic %q: %w", topicName, err)
+	}
+
+	zlog.Info("created temporary subscription", zap.Stringer("subscription", subscription))
+	return subscription, nil
+}
+
+func decodeTailMessage(message *pubsub.Message) *tailMessage {
+	decoded := &tailMessage{
+		ID:              message.ID,
+		PublishTime:     message.PublishTime,
+		OrderingKey:     message.OrderingKey,
+		Attributes:      map[string]string{},
+		DeliveryAttempt: message.DeliveryAttempt,
+		Step:            "New",
+	}
+
+	for key, value := range message.Attributes {
+		switch key {
+		case "Cursor":
+			if cursor, err := sink.NewCursor(value); err == nil && !cursor.IsBlank() {
+				decoded.BlockNumber = cursor.Block().Num()
+				decoded.BlockID = cursor.Block().ID()
+				continue
+			}
+		case "Step":
+			decoded.Step = value
+			decoded.Undo = value == "Undo"
+			continue
+		case "LastValidBlock":
+			decoded.LastValidBlock = value
+			continue
+		}
+
+		decoded.Attributes[key] = value
+	}
+
+	data := message.Data
+	if message.Attributes["ContentEncoding"] == "gzip" {
+		if decompressed, err := gunzip(data); err == nil {
+			data = decompressed
+		} else {
+			zlog.Warn("unable to decompress message, printing it as is", zap.String("id", message.ID), zap.Error(err))
+		}
+	}
+
+	if len(data) > 0 {
+		if utf8.Valid(data) {
+			decoded.Data = string(data)
+		} else {
+			decoded.Data = fmt.Sprintf("%x", data)
+			decoded.DataEncoding = "hex"
+		}
+	}
+
+	return decoded
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// tailChunks reassembles the messages split in chunks by the sink, chunks having the index
+// (starting at 0) and count attributes.
+type tailChunks struct {
+	index   string
+	count   string
+	pending map[string]map[int]*pubsub.Message
+}
+
+func newTailChunks(index, count string) *tailChunks {
+	return &tailChunks{index: index, count: count, pending: map[string]map[int]*pubsub.Message{}}
+}
+
+// add returns message if it's not a chunk, the reassembled message if it's the last missing
+// chunk of its message and nil otherwise. The reassembled message has the attributes and
+// ordering key of its chunks without the index and count attributes, and the ID and publish
+// time of its last received chunk.
+func (c *tailChunks) add(message *pubsub.Message) *pubsub.Message {
+	index, indexErr := strconv.Atoi(message.Attributes[c.index])
+	count, countErr := strconv.Atoi(message.Attributes[c.count])
+	if indexErr != nil || countErr != nil || count < 1 || index < 0 || index >= count {
+		return message
+	}
+
+	key := c.groupKey(message)
+	parts, found := c.pending[key]
+	if !found {
+		parts = map[int]*pubsub.Message{}
+		c.pending[key] = parts
+	}
+	parts[index] = message
+
+	if len(parts) < count {
+		return nil
+	}
+	delete(c.pending, key)
+
+	attributes := make(map[string]string, len(message.Attributes))
+	for key, value := range message.Attributes {
+		if key != c.index && key != c.count {
+			attributes[key] = value
+		}
+	}
+
+	var data []byte
+	for i := 0; i < count; i++ {
+		data = append(data, parts[i].Data...)
+	}
+
+	return &pubsub.Message{
+		ID:              message.ID,
+		Data:            data,
+		Attributes:      attributes,
+		PublishTime:     message.PublishTime,
+		DeliveryAttempt: message.DeliveryAttempt,
+		OrderingKey:     message.OrderingKey,
+	}
+}
+
+// groupKey identifies the message a chunk belongs to, chunks of the same message share every
+// attribute but the index, and their ordering key.
+func (c *tailChunks) groupKey(message *pubsub.Message) string {
+	keys := make([]string, 0, len(message.Attributes))
+	for key := range message.Attributes {
+		if key != c.index {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var group strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&group, "%s=%s\x00", key, message.Attributes[key])
+	}
+	group.WriteString(message.OrderingKey)
+
+	return group.String()
+}
+
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
+
+const tailTableFormat = "%-20s  %-12s  %-9s  %s"
+
+// tableRow formats the message for the table output, undo messages are printed in red
+// when highlight is true.
+func (m *tailMessage) tableRow(dataLimit int, highlight bool) string {
+	publishTime := m.PublishTime.UTC().Format(time.RFC3339)
+	block := "-"
+	if m.BlockNumber != 0 || m.BlockID != "" {
+		block = "#" + strconv.FormatUint(m.BlockNumber, 10)
+	}
+
+	if m.Undo {
+		row := fmt.Sprintf(tailTableFormat, publishTime, block, "UNDO", "last valid block #"+m.LastValidBlock)
+		if highlight {
+			row = "\033[31m" + row + "\033[0m"
+		}
+		return row
+	}
+
+	keys := make([]string, 0, len(m.Attributes))
+	for key := range m.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var details []string
+	for _, key := range keys {
+		details = append(details, key+"="+m.Attributes[key])
+	}
+	if m.OrderingKey != "" {
+		details = append(details, "ordering_key="+m.OrderingKey)
+	}
+
+	data := strings.ReplaceAll(m.Data, "\n", `\n`)
+	if dataLimit > 0 && len(data) > dataLimit {
+		data = data[:dataLimit] + "..."
+	}
+	if m.DataEncoding != "" {
+		data = m.DataEncoding + ":" + data
+	}
+	details = append(details, data)
+
+	return fmt.Sprintf(tailTableFormat, publishTime, block, strings.ToUpper(m.Step), strings.Join(details, " "))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/streamingfast/bstream"
+	sink "github.com/streamingfast/substreams-sink"
+	"github.com/stretchr/testify/require"
+)
+
+func tailTestCursor(blockNum uint64, blockID string) string {
+	block := bstream.NewBlockRef(blockID, blockNum)
+	return (&sink.Cursor{Cursor: &bstream.Cursor{Step: bstream.StepNew, Block: block, LIB: block, HeadBlock: block}}).String()
+}
+
+func TestDecodeTailMessageStep(t *testing.T) {
+	publishTime := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	cursor := tailTestCursor(4, "4a")
+
+	tests := []struct {
+		step     string
+		expected string
+	}{
+		{"", "NEW"},
+		{"BlockEnd", "BLOCKEND"},
+		{"Heartbeat", "HEARTBEAT"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			attributes := map[string]string{"Cursor": cursor}
+			if test.step != "" {
+				attributes["Step"] = test.step
+			}
+
+			decoded := decodeTailMessage(&pubsub.Message{ID: "1", PublishTime: publishTime, Attributes: attributes})
+			require.Equal(t, []string{"2023-11-14T22:13:20Z", "#4", test.expected}, strings.Fields(decoded.tableRow(0, false))[:3])
+			require.NotContains(t, decoded.Attributes, "Step")
+		})
+	}
+
+	undo := decodeTailMessage(&pubsub.Message{PublishTime: publishTime, Attributes: map[string]string{"Cursor": cursor, "Step": "Undo", "LastValidBlock": "3"}})
+	require.True(t, undo.Undo)
+	require.Equal(t, []string{"2023-11-14T22:13:20Z", "#4", "UNDO", "last", "valid", "block", "#3"}, strings.Fields(undo.tableRow(0, false)))
+}
+
+func TestDecodeTailMessageGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	decoded := decodeTailMessage(&pubsub.Message{Data: compressed.Bytes(), Attributes: map[string]string{"ContentEncoding": "gzip"}})
+	require.Equal(t, "hello", decoded.Data)
+	require.Empty(t, decoded.DataEncoding)
+
+	invalid := decodeTailMessage(&pubsub.Message{Data: []byte{0xff, 0x00}, Attributes: map[string]string{"ContentEncoding": "gzip"}})
+	require.Equal(t, "ff00", invalid.Data)
+	require.Equal(t, "hex", invalid.DataEncoding)
+}
+
+func TestTailChunks(t *testing.T) {
+	chunks := newTailChunks("Chunk", "Chunks")
+
+	chunk := func(id string, cursor string, index, count string, data string) *pubsub.Message {
+		return &pubsub.Message{ID: id, Data: []byte(data), Attributes: map[string]string{"Cursor": cursor, "Chunk": index, "Chunks": count}}
+	}
+
+	plain := &pubsub.Message{ID: "p", Data: []byte("plain"), Attributes: map[string]string{"Cursor": "c"}}
+	require.Same(t, plain, chunks.add(plain))
+
+	require.Nil(t, chunks.add(chunk("a2", "c4", "2", "3", "89")))
+	require.Nil(t, chunks.add(chunk("b0", "c5", "0", "2", "xy")))
+	require.Nil(t, chunks.add(chunk("a0", "c4", "0", "3", "0123")))
+
+	complete := chunks.add(chunk("a1", "c4", "1", "3", "4567"))
+	require.NotNil(t, complete)
+	require.Equal(t, "0123456789", string(complete.Data))
+	require.Equal(t, map[string]string{"Cursor": "c4"}, complete.Attributes)
+	require.Equal(t, "a1", complete.ID)
+
+	complete = chunks.add(chunk("b1", "c5", "1", "2", "z"))
+	require.NotNil(t, complete)
+	require.Equal(t, "xyz", string(complete.Data))
+	require.Empty(t, chunks.pending)
+
+	invalid := chunk("i", "c6", "3", "2", "data")
+	require.Same(t, invalid, chunks.add(invalid))
+}
+
+func TestTailChunksGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write([]byte("compressed then chunked"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	data := compressed.Bytes()
+	half := len(data) / 2
+	chunks := newTailChunks("Chunk", "Chunks")
+	attributes := func(index string) map[string]string {
+		return map[string]string{"Cursor": "c", "ContentEncoding": "gzip", "Chunk": index, "Chunks": "2"}
+	}
+
+	require.Nil(t, chunks.add(&pubsub.Message{Data: data[:half], Attributes: attributes("0")}))
+	complete := chunks.add(&pubsub.Message{Data: data[half:], Attributes: attributes("1")})
+	require.Equal(t, "compressed then chunked", decodeTailMessage(complete).Data)
+}
+
+func TestTailChunksEnvelope(t *testing.T) {
+	chunks := newTailChunks("Chunk", "Chunks")
+	envelopeChunks := newTailChunks("EnvelopeChunk", "EnvelopeChunks")
+
+	add := func(envelopeIndex, index, count string, data string) *pubsub.Message {
+		attributes := map[string]string{"Cursor": "c", "Envelope": "block", "EnvelopeChunk": envelopeIndex, "EnvelopeChunks": "2"}
+		if count != "" {
+			attributes["Chunk"] = index
+			attributes["Chunks"] = count
+		}
+
+		complete := chunks.add(&pubsub.Message{Data: []byte(data), Attributes: attributes})
+		if complete == nil {
+			return nil
+		}
+		return envelopeChunks.add(complete)
+	}
+
+	// The second envelope chunk is split further by the Chunk transformer
+	require.Nil(t, add("1", "1", "2", "89"))
+	require.Nil(t, add("0", "", "", "0123"))
+	complete := add("1", "0", "2", "4567")
+	require.NotNil(t, complete)
+	require.Equal(t, "0123456789", string(complete.Data))
+	require.Equal(t, map[string]string{"Cursor": "c", "Envelope": "block"}, complete.Attributes)
+	require.Empty(t, chunks.pending)
+	require.Empty(t, envelopeChunks.pending)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	. "github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
+	"google.golang.org/api/iterator"
+)
+
+var toolsTopicGroup = Group("topic", "Create, describe and delete PubSub topics",
+	PersistentFlags(addPubSubFlags),
+
+	Command(toolsTopicCreateE,
+		"create <topic-name>",
+		"Create a PubSub topic",
+		ExactArgs(1),
+		Flags(func(flags *pflag.FlagSet) {
+			flags.Duration("retention", 0, "How long published messages are retained by the topic, independently of subscriptions (between 10m and 31 days), not retained if 0")
+			flags.String("schema", "", "Schema messages must conform to, either an ID or a full 'projects/<project>/schemas/<id>' name")
+			flags.String("schema-encoding", "binary", "Encoding of messages validated against '--schema', either 'binary' or 'json'")
+			flags.String("kms-key", "", "Cloud KMS key protecting access to messages, in the form 'projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>'")
+			flags.StringToString("labels", nil, "Labels attached to the topic, e.g. 'team=data,env=prod'")
+		}),
+	),
+
+	Command(toolsTopicDescribeE,
+		"describe <topic-name>",
+		"Print a PubSub topic's configuration and subscriptions",
+		ExactArgs(1),
+	),
+
+	Command(toolsTopicDeleteE,
+		"delete <topic-name>",
+		"Delete a PubSub topic, its subscriptions are detached and stop receiving messages",
+		ExactArgs(1),
+		Flags(func(flags *pflag.FlagSet) {
+			flags.BoolP("yes", "y", false, "Do not ask for confirmation")
+		}),
+	),
+)
+
+var toolsSubscriptionGroup = Group("subscription", "Create PubSub subscriptions",
+	PersistentFlags(addPubSubFlags),
+
+	Command(toolsSubscriptionCreateE,
+		"create <subscription-name> <topic-name>",
+		"Create a pull subscription to a PubSub topic",
+		ExactArgs(2),
+		Flags(func(flags *pflag.FlagSet) {
+			flags.Bool("ordering", false, "Deliver messages sharing an ordering key in the order they were published")
+			flags.Duration("ack-deadline", 10*time.Second, "How long subscribers have to acknowledge a message before it's redelivered (between 10s and 10m)")
+			flags.Duration("retention", 7*24*time.Hour, "How long unacknowledged messages are retained (between 10m and 7 days)")
+			flags.Bool("retain-acked", false, "Retain acknowledged messages for '--retention', so they can be replayed with seek")
+			flags.Bool("exactly-once", false, "Enable exactly-once delivery")
+			flags.String("filter", "", "Only deliver messages whose attributes match this filter, e.g. 'attributes.Step = \"Undo\"'")
+			flags.String("dead-letter-topic", "", "Topic receiving messages that could not be delivered after '--max-delivery-attempts', either an ID or a full 'projects/<project>/topics/<id>' name")
+			flags.Int("max-delivery-attempts", 5, "Number of delivery attempts before a message is sent to '--dead-letter-topic' (between 5 and 100)")
+		}),
+	),
+)
+
+func toolsTopicCreateE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	client, err := newPubSubClient(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	config := &pubsub.TopicConfig{
+		Labels:     sflags.MustGetStringToString(cmd, "labels"),
+		KMSKeyName: sflags.MustGetString(cmd, "kms-key"),
+	}
+
+	if retention := sflags.MustGetDuration(cmd, "retention"); retention > 0 {
+		config.RetentionDuration = retention
+	}
+
+	if schema := sflags.MustGetString(cmd, "schema"); schema != "" {
+		encoding := pubsub.EncodingBinary
+		switch value := sflags.MustGetString(cmd, "schema-encoding"); value {
+		case "binary":
+		case "json":
+			encoding = pubsub.EncodingJSON
+		default:
+			return fmt.Errorf("invalid '--schema-encoding' %q, valid values are 'binary' or 'json'", value)
+		}
+
+		config.SchemaSettings = &pubsub.SchemaSettings{
+			Schema:   resourceName(client.Project(), "schemas", schema),
+			Encoding: encoding,
+		}
+	}
+
+	topic, err := client.CreateTopicWithConfig(ctx, args[0], config)
+	if err != nil {
+		return fmt.Errorf("creating topic %q: %w", args[0], err)
+	}
+
+	fmt.Printf("Topic %s created\n", topic)
+	return nil
+}
+
+func toolsTopicDescribeE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	client, err := newPubSubClient(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	topic := client.Topic(args[0])
+	config, err := topic.Config(ctx)
+	if err != nil {
+		return fmt.Errorf("getting topic %q: %w", args[0], err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Name\t%s\n", topic)
+	fmt.Fprintf(w, "Retention\t%s\n", valueOrNone(config.RetentionDuration))
+	if config.SchemaSettings != nil {
+		fmt.Fprintf(w, "Schema\t%s (%s)\n", config.SchemaSettings.Schema, schemaEncodingString(config.SchemaSettings.Encoding))
+	} else {
+		fmt.Fprintf(w, "Schema\t<none>\n")
+	}
+	fmt.Fprintf(w, "KMS key\t%s\n", valueOrNone(config.KMSKeyName))
+	fmt.Fprintf(w, "Labels\t%s\n", valueOrNone(config.Labels))
+
+	it := topic.Subscriptions(ctx)
+	for {
+		subscription, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("listing subscriptions: %w", err)
+		}
+
+		subscriptionConfig, err := subscription.Config(ctx)
+		if err != nil {
+			return fmt.Errorf("getting subscription %q: %w", subscription, err)
+		}
+
+		fmt.Fprintf(w, "Subscription\t%s (ordering: %t, ack deadline: %s, retention: %s)\n",
+			subscription, subscriptionConfig.EnableMessageOrdering, subscriptionConfig.AckDeadline, subscriptionConfig.RetentionDuration)
+	}
+
+	return w.Flush()
+}
+
+func toolsTopicDeleteE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	client, err := newPubSubClient(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	topic := client.Topic(args[0])
+	if err := confirm(cmd, fmt.Sprintf("Delete topic %s", topic)); err != nil {
+		return err
+	}
+
+	if err := topic.Delete(ctx); err != nil {
+		return fmt.Errorf("deleting topic %q: %w", args[0], err)
+	}
+
+	fmt.Printf("Topic %s deleted\n", topic)
+	return nil
+}
+
+func toolsSubscriptionCreateE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	client, err := newPubSubClient(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	config := pubsub.SubscriptionConfig{
+		Topic:                     client.Topic(args[1]),
+		AckDeadline:               sflags.MustGetDuration(cmd, "ack-deadline"),
+		RetentionDuration:         sflags.MustGetDuration(cmd, "retention"),
+		RetainAckedMessages:       sflags.MustGetBool(cmd, "retain-acked"),
+		EnableMessageOrdering:     sflags.MustGetBool(cmd, "ordering"),
+		EnableExactlyOnceDelivery: sflags.MustGetBool(cmd, "exactly-once"),
+		Filter:                    sflags.MustGetString(cmd, "filter"),
+	}
+
+	if deadLetterTopic := sflags.MustGetString(cmd, "dead-letter-topic"); deadLetterTopic != "" {
+		config.DeadLetterPolicy = &pubsub.DeadLetterPolicy{
+			DeadLetterTopic:     resourceName(client.Project(), "topics", deadLetterTopic),
+			MaxDeliveryAttempts: sflags.MustGetInt(cmd, "max-delivery-attempts"),
+		}
+	}
+
+	subscription, err := client.CreateSubscription(ctx, args[0], config)
+	if err != nil {
+		return fmt.Errorf("creating subscription %q: %w", args[0], err)
+	}
+
+	fmt.Printf("Subscription %s created\n", subscription)
+	return nil
+}
+
+// resourceName returns the full 'projects/<project>/<collection>/<id>' name of idOrName,
+// unless it's already a full name.
+func resourceName(project string, collection string, idOrName string) string {
+	if strings.HasPrefix(idOrName, "projects/") {
+		return idOrName
+	}
+
+	return fmt.Sprintf("projects/%s/%s/%s", project, collection, idOrName)
+}
+
+func schemaEncodingString(encoding pubsub.SchemaEncoding) string {
+	switch encoding {
+	case pubsub.EncodingJSON:
+		return "json"
+	case pubsub.EncodingBinary:
+		return "binary"
+	}
+
+	return "unspecified"
+}
+
+func valueOrNone(value any) string {
+	out := fmt.Sprint(value)
+	if value == nil || out == "" || out == "map[]" {
+		return "<none>"
+	}
+
+	return out
+}
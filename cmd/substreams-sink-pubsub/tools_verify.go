@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	. "github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
+	sink "github.com/streamingfast/substreams-sink"
+	"go.uber.org/zap"
+
+	spubsub "github.com/streamingfast/substreams-sink-pubsub"
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+// verifyMaxListed is the number of problems of each kind printed by 'tools verify'.
+const verifyMaxListed = 20
+
+var toolsVerifyCmd = Command(toolsVerifyE,
+	"verify <start>:<stop> [<subscription-name>]",
+	"Check the messages of a block range received by a subscription or written to a file archive",
+	RangeArgs(1, 2),
+	Flags(func(flags *pflag.FlagSet) {
+		sink.AddFlagsToSet(flags)
+
+		addPubSubFlags(flags)
+		flags.String("archive", "", "Verify the messages written by a 'file://' destination to this file or directory instead of a subscription")
+		flags.Duration("idle-timeout", 30*time.Second, "Stop reading the subscription once no message has been received for this long")
+		flags.Bool("ack", false, "Acknowledge the messages read from the subscription, when false they are nacked and redelivered to other consumers")
+		flags.String("manifest", "", "URL or local path to the Substreams manifest, with '--module' the module is run over the block range to compute the expected number of messages per block")
+		flags.String("module", "", "Name of the module publishing the messages, see '--manifest'")
+		flags.StringP("endpoint", "e", "", "Substreams gRPC endpoint (e.g. 'mainnet.eth.streamingfast.io:443'), used with '--manifest'")
+		flags.String("envelope", "message", "How the sink published the module's messages, 'message' or 'block', see 'sink --envelope', used with '--manifest'")
+		flags.String("config", "", "Path of the sink's configuration file, its 'publish.envelope', 'filters' and 'rename_attributes' are applied when computing the expected number of messages with '--manifest'")
+	}),
+	Description(`
+		Check the messages of a block range received by a subscription or written to a file
+		archive, '--archive', reporting:
+		- gaps, blocks without messages;
+		- duplicate messages, identified by their ID for destinations keeping the one assigned
+		  by the sink, or by their block and content otherwise;
+		- messages received after a message of a later block sharing their ordering key;
+		- undo messages not followed by the messages of the new chain segment;
+		- blocks whose number of messages differs from the expected one.
+
+		With '--manifest' and '--module', the module is run over <start>:<stop> to compute the
+		expected number of messages per block, published like the sink did with '--envelope'
+		and the '--config' file's filters and renames. Otherwise blocks without messages can't
+		be told apart from missing ones, only the blocks of the range before the first message
+		and after the last one are reported as gaps.
+
+		Messages are expected in the order they were published, so a subscription should have
+		message ordering enabled. Reading a subscription stops once no message has been received
+		for '--idle-timeout'.
+
+		The command exits with an error if any problem is found.
+	`),
+	ExamplePrefixed("substreams-sink-pubsub tools verify", `
+		# Verify the files written by '--destination file://./archive'
+		18000000:18010000 --archive ./archive
+		# Verify a subscription against the messages produced by map_clocks
+		18000000:18010000 dev-topic-sub --project acme --manifest ./examples/simple/substreams.yaml --module map_clocks
+	`),
+)
+
+func toolsVerifyE(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	blockRange, err := parseBlockRange(args[0])
+	if err != nil {
+		return err
+	}
+
+	archive := sflags.MustGetString(cmd, "archive")
+	if (len(args) == 2) == (archive != "") {
+		return fmt.Errorf("either <subscription-name> or '--archive' must be set, but not both")
+	}
+
+	verifier := spubsub.NewVerifier(blockRange)
+
+	if manifestPath := sflags.MustGetString(cmd, "manifest"); manifestPath != "" {
+		opts, err := verifySinkOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		counts, err := expectedMessageCounts(ctx, cmd, manifestPath, args[0], blockRange, opts...)
+		if err != nil {
+			return fmt.Errorf("computing expected message counts: %w", err)
+		}
+
+		verifier.ExpectCounts(counts)
+	}
+
+	if archive != "" {
+		err = publisher.ReadFiles(archive, func(record *publisher.FileRecord) error {
+			verifier.Add(record.Message())
+			return ctx.Err()
+		})
+	} else {
+		err = verifySubscription(ctx, cmd, args[1], verifier)
+	}
+	if err != nil {
+		return err
+	}
+
+	report := verifier.Report()
+	printVerifyReport(blockRange, report)
+
+	if !report.OK() {
+		return fmt.Errorf("verification failed")
+	}
+
+	return nil
+}
+
+func verifySubscription(ctx context.Context, cmd *cobra.Command, subscriptionName string, verifier *spubsub.Verifier) error {
+	client, err := newPubSubClient(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	subscription := client.Subscription(subscriptionName)
+	subscription.ReceiveSettings.NumGoroutines = 1
+
+	ack := sflags.MustGetBool(cmd, "ack")
+	idleTimeout := sflags.MustGetDuration(cmd, "idle-timeout")
+
+	receiveCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var lock sync.Mutex
+	// Nacked messages are redelivered with the same ID, unlike messages published twice
+	received := map[string]bool{}
+	idle := time.AfterFunc(idleTimeout, stop)
+
+	err = subscription.Receive(receiveCtx, func(_ context.Context, message *pubsub.Message) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if ack {
+			defer message.Ack()
+		} else {
+			defer message.Nack()
+		}
+
+		if received[message.ID] {
+			return
+		}
+		received[message.ID] = true
+		idle.Reset(idleTimeout)
+
+		// The sink's ID is not carried by PubSub, identity is derived from the content
+		message.ID = ""
+		verifier.Add(message)
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("receiving from subscription %q: %w", subscriptionName, err)
+	}
+
+	return ctx.Err()
+}
+
+// verifySinkOptions returns the options the sink published with, from '--envelope' and the
+// '--config' file, the flag taking precedence over the file's envelope.
+func verifySinkOptions(cmd *cobra.Command) ([]spubsub.Option, error) {
+	config := &spubsub.Config{}
+	if path := sflags.MustGetString(cmd, "config"); path != "" {
+		loaded, err := spubsub.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		config = loaded
+	}
+
+	envelope := sflags.MustGetString(cmd, "envelope")
+	if flag := cmd.Flags().Lookup("envelope"); !flag.Changed && !flagEnvSet(flag) && config.Publish.Envelope != "" {
+		envelope = config.Publish.Envelope
+	}
+	if envelope != "message" && envelope != "block" {
+		return nil, fmt.Errorf("invalid '--envelope' %q, expected 'message' or 'block'", envelope)
+	}
+
+	opts, err := configOptions(config, envelope)
+	if err != nil {
+		return nil, err
+	}
+	if envelope == "block" {
+		opts = append(opts, spubsub.WithBlockEnvelope())
+	}
+
+	return opts, nil
+}
+
+// expectedMessageCounts runs the module over the block range, counting the messages
+// published for each block, with the sink's opts, without publishing them.
+func expectedMessageCounts(ctx context.Context, cmd *cobra.Command, manifestPath string, rawBlockRange string, blockRange spubsub.BlockRange, opts ...spubsub.Option) (map[uint64]int, error) {
+	module := sflags.MustGetString(cmd, "module")
+	if module == "" {
+		return nil, fmt.Errorf("'--module' is required with '--manifest'")
+	}
+
+	endpoint, err := resolveEndpoint(cmd, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sinker, err := sink.NewFromViper(
+		cmd,
+		publishOutputType,
+		endpoint, manifestPath, module, rawBlockRange,
+		zlog, tracer,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to setup sinker: %w", err)
+	}
+
+	counter := &verifierPublisher{verifier: spubsub.NewVerifier(blockRange)}
+
+	s, err := spubsub.New(append([]spubsub.Option{spubsub.WithSinker(sinker), spubsub.WithPublisher(counter), spubsub.WithLogger(zlog), spubsub.WithDryRun()}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("creating sink: %w", err)
+	}
+	s.Run(ctx)
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	zlog.Info("computed expected message counts", zap.Int("blocks", len(counter.verifier.Counts())))
+	return counter.verifier.Counts(), nil
+}
+
+// verifierPublisher is a [publisher.Publisher] adding the messages to a verifier instead of
+// publishing them.
+type verifierPublisher struct {
+	verifier *spubsub.Verifier
+}
+
+func (p *verifierPublisher) Publish(_ context.Context, messages []*pubsub.Message) []publisher.Result {
+	results := make([]publisher.Result, 0, len(messages))
+	for _, message := range messages {
+		p.verifier.Add(message)
+		results = append(results, verifiedResult(message.ID))
+	}
+
+	return results
+}
+
+func (p *verifierPublisher) Flush(_ context.Context) error {
+	return nil
+}
+
+func (p *verifierPublisher) Close() error {
+	return nil
+}
+
+type verifiedResult string
+
+func (r verifiedResult) Get(_ context.Context) (string, error) {
+	return string(r), nil
+}
+
+// parseBlockRange parses a '<start>:<stop>' block range, stop being exclusive like for the
+// sink's block range.
+func parseBlockRange(raw string) (spubsub.BlockRange, error) {
+	rawStart, rawStop, found := strings.Cut(raw, ":")
+	start, startErr := strconv.ParseUint(rawStart, 10, 64)
+	stop, stopErr := strconv.ParseUint(rawStop, 10, 64)
+	if !found || startErr != nil || stopErr != nil || stop <= start {
+		return spubsub.BlockRange{}, fmt.Errorf("invalid block range %q, expected '<start>:<stop>' with <stop> greater than <start>", raw)
+	}
+
+	return spubsub.BlockRange{Start: start, Stop: stop - 1}, nil
+}
+
+func printVerifyReport(blockRange spubsub.BlockRange, report *spubsub.VerifyReport) {
+	fmt.Printf("Verified %d messages over %d blocks in %s\n", report.Messages, report.Blocks, blockRange)
+
+	printVerifyProblems("Gaps", len(report.Gaps), func(i int) string {
+		return report.Gaps[i].String()
+	})
+	printVerifyProblems("Count mismatches", len(report.CountMismatches), func(i int) string {
+		mismatch := report.CountMismatches[i]
+		return fmt.Sprintf("#%d: expected %d messages, got %d", mismatch.BlockNumber, mismatch.Expected, mismatch.Actual)
+	})
+	printVerifyProblems("Duplicate messages", len(report.Duplicates), func(i int) string {
+		return report.Duplicates[i]
+	})
+	printVerifyProblems("Out of order messages", len(report.OutOfOrder), func(i int) string {
+		violation := report.OutOfOrder[i]
+		return fmt.Sprintf("ordering key %q: #%d received after #%d", violation.OrderingKey, violation.BlockNumber, violation.AfterBlock)
+	})
+	printVerifyProblems("Unresolved undos", len(report.UnresolvedUndos), func(i int) string {
+		return report.UnresolvedUndos[i].String() + " undone and never replaced"
+	})
+
+	if report.OK() {
+		fmt.Println("No problem found")
+	}
+}
+
+func printVerifyProblems(title string, count int, describe func(i int) string) {
+	if count == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s (%d)\n", title, count)
+	for i := 0; i < count && i < verifyMaxListed; i++ {
+		fmt.Printf("  %s\n", describe(i))
+	}
+	if count > verifyMaxListed {
+		fmt.Printf("  ... and %d more\n", count-verifyMaxListed)
+	}
+}
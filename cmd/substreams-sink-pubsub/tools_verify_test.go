@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySinkOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sink.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("publish:\n  envelope: block\nfilters:\n  - attribute: type\n    values: [transfer]\n"), 0644))
+
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("envelope", "message", "")
+		cmd.Flags().String("config", "", "")
+		require.NoError(t, cmd.ParseFlags(args))
+		return cmd
+	}
+
+	opts, err := verifySinkOptions(newCmd())
+	require.NoError(t, err)
+	require.Empty(t, opts)
+
+	opts, err = verifySinkOptions(newCmd("--envelope", "block"))
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+
+	// The file's envelope applies unless the flag is set
+	_, err = verifySinkOptions(newCmd("--config", path))
+	require.ErrorContains(t, err, "not supported with '--envelope=block'")
+
+	opts, err = verifySinkOptions(newCmd("--config", path, "--envelope", "message"))
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+
+	_, err = verifySinkOptions(newCmd("--envelope", "chunk"))
+	require.ErrorContains(t, err, "invalid '--envelope'")
+}
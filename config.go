@@ -0,0 +1,284 @@
+package substreams_sink_pubsub
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the sink's configuration file, in YAML ('.yaml' or '.yml') or TOML ('.toml').
+// Unknown keys are rejected. Every value is optional in the file, see [Config.Validate] for
+// the values required to run the sink. A process publishes to a single destination and topic,
+// routing messages to several topics is not supported, but some destinations expand templates
+// in the topic, see the sink's <topic-name> argument. Flags without a key are only set on the
+// command line or through environment variables.
+type Config struct {
+	Substreams SubstreamsConfig `yaml:"substreams" toml:"substreams"`
+
+	// Topic is the PubSub topic, or the destination's equivalent, messages are published to.
+	Topic string `yaml:"topic" toml:"topic"`
+
+	// Destination is where messages are published, see the sink's '--destination' flag.
+	Destination string `yaml:"destination" toml:"destination"`
+
+	// CursorPath is the directory the cursor is saved in.
+	CursorPath string `yaml:"cursor_path" toml:"cursor_path"`
+
+	Checkpoint CheckpointConfig `yaml:"checkpoint" toml:"checkpoint"`
+	Publish    PublishConfig    `yaml:"publish" toml:"publish"`
+	Health     HealthConfig     `yaml:"health" toml:"health"`
+	PubSub     PubSubConfig     `yaml:"pubsub" toml:"pubsub"`
+	Webhook    WebhookConfig    `yaml:"webhook" toml:"webhook"`
+
+	// Attributes are added to every message, overriding the module's attributes of the same name.
+	Attributes map[string]string `yaml:"attributes" toml:"attributes"`
+
+	// Filters drop messages, a message is published only if every filter keeps it, see
+	// [Config.Options].
+	Filters []FilterConfig `yaml:"filters" toml:"filters"`
+
+	// RenameAttributes renames the attributes of each message, mapping the current name to the
+	// new one, after the filters, see [RenameAttributes].
+	RenameAttributes map[string]string `yaml:"rename_attributes" toml:"rename_attributes"`
+}
+
+// SubstreamsConfig describes the Substreams module streamed by the sink.
+type SubstreamsConfig struct {
+	Endpoint   string   `yaml:"endpoint" toml:"endpoint"`
+	Network    string   `yaml:"network" toml:"network"`
+	Manifest   string   `yaml:"manifest" toml:"manifest"`
+	Module     string   `yaml:"module" toml:"module"`
+	BlockRange string   `yaml:"block_range" toml:"block_range"`
+	Params     []string `yaml:"params" toml:"params"`
+
+	UndoBufferSize  *int  `yaml:"undo_buffer_size" toml:"undo_buffer_size"`
+	FinalBlocksOnly *bool `yaml:"final_blocks_only" toml:"final_blocks_only"`
+}
+
+// CheckpointConfig configures how often the cursor is saved, see the sink's '--checkpoint-*'
+// flags.
+type CheckpointConfig struct {
+	Blocks   *int   `yaml:"blocks" toml:"blocks"`
+	Interval string `yaml:"interval" toml:"interval"`
+}
+
+// PublishConfig configures how messages are published, see the sink's flags of the same names.
+type PublishConfig struct {
+	Envelope             string `yaml:"envelope" toml:"envelope"`
+	BlockEndMarker       *bool  `yaml:"block_end_marker" toml:"block_end_marker"`
+	HeartbeatInterval    string `yaml:"heartbeat_interval" toml:"heartbeat_interval"`
+	CreateTopicIfMissing *bool  `yaml:"create_topic_if_missing" toml:"create_topic_if_missing"`
+	DrainTimeout         string `yaml:"drain_timeout" toml:"drain_timeout"`
+}
+
+// HealthConfig configures the health probes, see the sink's '--health-*' flags.
+type HealthConfig struct {
+	ListenAddr       string `yaml:"listen_addr" toml:"listen_addr"`
+	BlockTimeout     string `yaml:"block_timeout" toml:"block_timeout"`
+	MaxPublishErrors *int   `yaml:"max_publish_errors" toml:"max_publish_errors"`
+}
+
+// FilterConfig keeps the messages whose Attribute is one of Values, dropping the others,
+// including the messages without the attribute.
+type FilterConfig struct {
+	Attribute string   `yaml:"attribute" toml:"attribute"`
+	Values    []string `yaml:"values" toml:"values"`
+}
+
+// PubSubConfig configures the PubSub client, see the sink's '--pubsub-*' flags.
+type PubSubConfig struct {
+	Project                   string `yaml:"project" toml:"project"`
+	Endpoint                  string `yaml:"endpoint" toml:"endpoint"`
+	Region                    string `yaml:"region" toml:"region"`
+	Insecure                  *bool  `yaml:"insecure" toml:"insecure"`
+	CredentialsFile           string `yaml:"credentials_file" toml:"credentials_file"`
+	ImpersonateServiceAccount string `yaml:"impersonate_service_account" toml:"impersonate_service_account"`
+	Emulator                  string `yaml:"emulator" toml:"emulator"`
+}
+
+// WebhookConfig configures the webhook destination, see the sink's '--webhook-*' flags.
+type WebhookConfig struct {
+	PerBlock     *bool  `yaml:"per_block" toml:"per_block"`
+	Timeout      string `yaml:"timeout" toml:"timeout"`
+	MaxRetries   *int   `yaml:"max_retries" toml:"max_retries"`
+	Concurrency  *int   `yaml:"concurrency" toml:"concurrency"`
+	SecretEnvvar string `yaml:"secret_envvar" toml:"secret_envvar"`
+}
+
+var (
+	blockRangeRegex   = regexp.MustCompile(`^\d*(:\+?\d*)?$`)
+	destinationScheme = map[string]bool{"kafka": true, "nats": true, "redis": true, "rediss": true, "amqp": true, "amqps": true, "http": true, "https": true, "file": true}
+
+	// reservedAttributes are set by the sink on its messages.
+	reservedAttributes = map[string]bool{"Cursor": true, "Step": true, "LastValidBlock": true}
+)
+
+// LoadConfig reads and validates the configuration file at path, its format is picked from
+// its extension.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	config := &Config{}
+	switch extension := strings.ToLower(filepath.Ext(path)); extension {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("decoding YAML config file %q: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("decoding TOML config file %q: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q, valid values are '.yaml', '.yml' or '.toml'", extension)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %q: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Validate returns an error if a value of the configuration is invalid. Positional values
+// like the manifest, module and topic are not required since they can be passed on the
+// command line.
+func (c *Config) Validate() error {
+	if c.Substreams.BlockRange != "" && !blockRangeRegex.MatchString(c.Substreams.BlockRange) {
+		return fmt.Errorf("substreams.block_range %q is invalid, expected '<start>:<stop>', '<start>:', ':<stop>' or '<start>:+<count>'", c.Substreams.BlockRange)
+	}
+
+	for _, param := range c.Substreams.Params {
+		if !strings.Contains(param, "=") {
+			return fmt.Errorf("substreams.params value %q is invalid, expected '<module>=<value>'", param)
+		}
+	}
+
+	if c.Destination != "" && c.Destination != "pubsub" && c.Destination != "stdout" {
+		destinationURL, err := url.Parse(c.Destination)
+		if err != nil {
+			return fmt.Errorf("destination %q is invalid: %w", c.Destination, err)
+		}
+		if !destinationScheme[destinationURL.Scheme] {
+			return fmt.Errorf("destination %q is invalid, expected 'pubsub', 'stdout' or a 'kafka', 'nats', 'redis[s]', 'amqp[s]', 'http[s]' or 'file' URL", c.Destination)
+		}
+	}
+
+	if c.Substreams.UndoBufferSize != nil && *c.Substreams.UndoBufferSize < 0 {
+		return fmt.Errorf("substreams.undo_buffer_size must be positive")
+	}
+
+	if c.Checkpoint.Blocks != nil && *c.Checkpoint.Blocks < 0 {
+		return fmt.Errorf("checkpoint.blocks must be positive")
+	}
+
+	if c.Publish.Envelope != "" && c.Publish.Envelope != "message" && c.Publish.Envelope != "block" {
+		return fmt.Errorf("publish.envelope %q is invalid, valid values are 'message' or 'block'", c.Publish.Envelope)
+	}
+
+	if c.Health.MaxPublishErrors != nil && *c.Health.MaxPublishErrors < 0 {
+		return fmt.Errorf("health.max_publish_errors must be positive")
+	}
+
+	for key, value := range map[string]string{
+		"checkpoint.interval":        c.Checkpoint.Interval,
+		"publish.heartbeat_interval": c.Publish.HeartbeatInterval,
+		"publish.drain_timeout":      c.Publish.DrainTimeout,
+		"health.block_timeout":       c.Health.BlockTimeout,
+		"webhook.timeout":            c.Webhook.Timeout,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s %q is invalid: %w", key, value, err)
+		}
+	}
+
+	if c.PubSub.Region != "" && c.PubSub.Endpoint != "" {
+		return fmt.Errorf("pubsub.region and pubsub.endpoint are exclusive")
+	}
+
+	if c.Webhook.MaxRetries != nil && *c.Webhook.MaxRetries < 0 {
+		return fmt.Errorf("webhook.max_retries must be positive")
+	}
+
+	if c.Webhook.Concurrency != nil && *c.Webhook.Concurrency <= 0 {
+		return fmt.Errorf("webhook.concurrency must be greater than 0")
+	}
+
+	for key := range c.Attributes {
+		if err := validateAttributeKey("attributes", key); err != nil {
+			return err
+		}
+	}
+
+	for i, filter := range c.Filters {
+		if filter.Attribute == "" {
+			return fmt.Errorf("filters[%d].attribute is required", i)
+		}
+		if len(filter.Values) == 0 {
+			return fmt.Errorf("filters[%d].values is required, a filter without values would drop every message", i)
+		}
+	}
+
+	for from, to := range c.RenameAttributes {
+		if err := validateAttributeKey("rename_attributes", from); err != nil {
+			return err
+		}
+		if err := validateAttributeKey("rename_attributes", to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateAttributeKey(section string, key string) error {
+	if reservedAttributes[key] {
+		return fmt.Errorf("%s key %q is reserved, it's set by the sink", section, key)
+	}
+	if key == "" || strings.HasPrefix(key, "goog") {
+		return fmt.Errorf("%s key %q is invalid, it must not be empty nor start with 'goog'", section, key)
+	}
+	return nil
+}
+
+// Options returns the options applying the configuration's filters and attribute renames.
+// The other values are applied through the sink's flags.
+func (c *Config) Options() []Option {
+	var opts []Option
+	for _, filter := range c.Filters {
+		values := map[string]bool{}
+		for _, value := range filter.Values {
+			values[value] = true
+		}
+
+		attribute := filter.Attribute
+		opts = append(opts, WithFilter(func(_ *BlockContext, message *pubsub.Message) bool {
+			value, found := message.Attributes[attribute]
+			return found && values[value]
+		}))
+	}
+
+	if len(c.RenameAttributes) > 0 {
+		opts = append(opts, WithTransformer(RenameAttributes(c.RenameAttributes)))
+	}
+
+	return opts
+}
@@ -0,0 +1,192 @@
+package substreams_sink_pubsub
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	perBlock := true
+	concurrency := 4
+	undoBufferSize := 24
+	checkpointBlocks := 1000
+	expected := &Config{
+		Substreams: SubstreamsConfig{
+			Endpoint:       "mainnet.eth.streamingfast.io:443",
+			Manifest:       "./substreams.yaml",
+			Module:         "map_clocks",
+			BlockRange:     "0:1000",
+			Params:         []string{"map_clocks=1"},
+			UndoBufferSize: &undoBufferSize,
+		},
+		Topic:            "dev-topic",
+		Destination:      "https://example.com/hook",
+		CursorPath:       "./state",
+		PubSub:           PubSubConfig{Project: "acme", Region: "us-east1"},
+		Webhook:          WebhookConfig{PerBlock: &perBlock, Timeout: "10s", Concurrency: &concurrency},
+		Checkpoint:       CheckpointConfig{Blocks: &checkpointBlocks, Interval: "10s"},
+		Publish:          PublishConfig{Envelope: "message"},
+		Attributes:       map[string]string{"Env": "prod"},
+		Filters:          []FilterConfig{{Attribute: "Type", Values: []string{"Transfer", "Approval"}}},
+		RenameAttributes: map[string]string{"Type": "EventType"},
+	}
+
+	config, err := LoadConfig(write("sink.yaml", `
+substreams:
+  endpoint: mainnet.eth.streamingfast.io:443
+  manifest: ./substreams.yaml
+  module: map_clocks
+  block_range: "0:1000"
+  params: ["map_clocks=1"]
+  undo_buffer_size: 24
+topic: dev-topic
+destination: https://example.com/hook
+cursor_path: ./state
+pubsub:
+  project: acme
+  region: us-east1
+webhook:
+  per_block: true
+  timeout: 10s
+  concurrency: 4
+checkpoint:
+  blocks: 1000
+  interval: 10s
+publish:
+  envelope: message
+attributes:
+  Env: prod
+filters:
+  - attribute: Type
+    values: [Transfer, Approval]
+rename_attributes:
+  Type: EventType
+`))
+	require.NoError(t, err)
+	require.Equal(t, expected, config)
+
+	config, err = LoadConfig(write("sink.toml", `
+topic = "dev-topic"
+destination = "https://example.com/hook"
+cursor_path = "./state"
+
+[substreams]
+endpoint = "mainnet.eth.streamingfast.io:443"
+manifest = "./substreams.yaml"
+module = "map_clocks"
+block_range = "0:1000"
+params = ["map_clocks=1"]
+undo_buffer_size = 24
+
+[checkpoint]
+blocks = 1000
+interval = "10s"
+
+[publish]
+envelope = "message"
+
+[pubsub]
+project = "acme"
+region = "us-east1"
+
+[webhook]
+per_block = true
+timeout = "10s"
+concurrency = 4
+
+[attributes]
+Env = "prod"
+
+[[filters]]
+attribute = "Type"
+values = ["Transfer", "Approval"]
+
+[rename_attributes]
+Type = "EventType"
+`))
+	require.NoError(t, err)
+	require.Equal(t, expected, config)
+
+	_, err = LoadConfig(write("unknown.yaml", "topics: dev-topic\n"))
+	require.ErrorContains(t, err, "field topics not found")
+
+	_, err = LoadConfig(write("unknown.toml", "topics = \"dev-topic\"\n"))
+	require.ErrorContains(t, err, "strict mode")
+
+	_, err = LoadConfig(write("sink.json", "{}"))
+	require.ErrorContains(t, err, "unsupported config file extension")
+}
+
+func TestConfigValidate(t *testing.T) {
+	zero := 0
+
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"empty", Config{}, ""},
+		{"open block range", Config{Substreams: SubstreamsConfig{BlockRange: "100:"}}, ""},
+		{"invalid block range", Config{Substreams: SubstreamsConfig{BlockRange: "a-b"}}, "substreams.block_range"},
+		{"invalid params", Config{Substreams: SubstreamsConfig{Params: []string{"value"}}}, "substreams.params"},
+		{"stdout destination", Config{Destination: "stdout"}, ""},
+		{"invalid destination", Config{Destination: "ftp://host"}, "destination"},
+		{"region and endpoint", Config{PubSub: PubSubConfig{Region: "us-east1", Endpoint: "host:443"}}, "exclusive"},
+		{"invalid webhook timeout", Config{Webhook: WebhookConfig{Timeout: "10"}}, "webhook.timeout"},
+		{"invalid checkpoint interval", Config{Checkpoint: CheckpointConfig{Interval: "1h/2"}}, "checkpoint.interval"},
+		{"invalid envelope", Config{Publish: PublishConfig{Envelope: "batch"}}, "publish.envelope"},
+		{"invalid webhook concurrency", Config{Webhook: WebhookConfig{Concurrency: &zero}}, "webhook.concurrency"},
+		{"reserved attribute", Config{Attributes: map[string]string{"Cursor": "x"}}, "reserved"},
+		{"google attribute", Config{Attributes: map[string]string{"googKey": "x"}}, "goog"},
+		{"filter without attribute", Config{Filters: []FilterConfig{{Values: []string{"x"}}}}, "filters[0].attribute"},
+		{"filter without values", Config{Filters: []FilterConfig{{Attribute: "Type"}}}, "filters[0].values"},
+		{"rename to reserved attribute", Config{RenameAttributes: map[string]string{"Type": "Step"}}, "reserved"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.Validate()
+			if test.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	config := &Config{
+		Filters:          []FilterConfig{{Attribute: "Type", Values: []string{"Transfer", "Approval"}}},
+		RenameAttributes: map[string]string{"Type": "EventType"},
+	}
+
+	s := newSink()
+	for _, opt := range config.Options() {
+		opt(s)
+	}
+
+	messages, err := s.transform(context.Background(), &BlockContext{}, []*pubsub.Message{
+		{ID: "1", Attributes: map[string]string{"Type": "Transfer"}},
+		{ID: "2", Attributes: map[string]string{"Type": "Mint"}},
+		{ID: "3", Attributes: map[string]string{}},
+		{ID: "4", Attributes: map[string]string{"Type": "Approval"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*pubsub.Message{
+		{ID: "1", Attributes: map[string]string{"EventType": "Transfer"}},
+		{ID: "4", Attributes: map[string]string{"EventType": "Approval"}},
+	}, messages)
+}
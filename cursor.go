@@ -0,0 +1,240 @@
+package substreams_sink_pubsub
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sink "github.com/streamingfast/substreams-sink"
+)
+
+const (
+	cursorFilename        = "cursor.json"
+	cursorHistoryFilename = "cursor-history.jsonl"
+	fencingTokenFilename  = "fencing-token"
+	cursorLockFilename    = "cursor.lock"
+)
+
+// ErrFenced is returned when saving the cursor of a leader replaced by a newer one, see
+// [ClaimFencingToken].
+var ErrFenced = errors.New("cursor fenced by a newer leader")
+
+// CursorHistoryEntry records a manual change of the cursor made by an operator.
+type CursorHistoryEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Previous string    `json:"previous,omitempty"`
+	Cursor   string    `json:"cursor,omitempty"`
+}
+
+// CursorStore keeps the sink's cursor, the position in the stream where it restarts.
+type CursorStore interface {
+	// Load returns the saved cursor, nil if there is none.
+	Load() (*sink.Cursor, error)
+
+	// Save replaces the saved cursor.
+	Save(cursor *sink.Cursor) error
+}
+
+// FileCursorStore is a [CursorStore] keeping the cursor in a directory, see [LoadCursor] and
+// [SaveCursor].
+//
+// With a fencing token, the token is checked and the cursor written under an advisory lock
+// shared with [ClaimFencingToken], so that a new leader's claim can't happen in between. The
+// lock only serializes the processes of a host sharing the directory on a local filesystem,
+// network filesystems may not honor it.
+type FileCursorStore struct {
+	path         string
+	fencingToken uint64
+}
+
+// NewFileCursorStore creates a [FileCursorStore] keeping the cursor in the path directory.
+func NewFileCursorStore(path string) *FileCursorStore {
+	return &FileCursorStore{path: path}
+}
+
+// SetFencingToken makes [FileCursorStore.Save] fail with [ErrFenced] once a leader with a
+// higher fencing token was elected, see [ClaimFencingToken].
+func (s *FileCursorStore) SetFencingToken(token uint64) {
+	s.fencingToken = token
+}
+
+func (s *FileCursorStore) Load() (*sink.Cursor, error) {
+	return LoadCursor(s.path)
+}
+
+func (s *FileCursorStore) Save(cursor *sink.Cursor) error {
+	if s.fencingToken == 0 {
+		return SaveCursor(s.path, cursor)
+	}
+
+	unlock, err := lockCursorPath(s.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := CheckFencingToken(s.path, s.fencingToken); err != nil {
+		return err
+	}
+
+	return SaveCursor(s.path, cursor)
+}
+
+// LoadCursor reads the cursor saved in cursorPath, returning nil if there is none.
+func LoadCursor(cursorPath string) (*sink.Cursor, error) {
+	fpath := filepath.Join(cursorPath, cursorFilename)
+
+	_, err := os.Stat(fpath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	cursorData, err := os.ReadFile(fpath)
+
+	if err != nil {
+		return nil, fmt.Errorf("reading cursor file: %w", err)
+	}
+
+	cursorString := string(cursorData)
+	cursor, err := sink.NewCursor(cursorString)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cursor: %w", err)
+	}
+
+	return cursor, nil
+}
+
+// SaveCursor writes c in cursorPath, creating the directory if needed.
+func SaveCursor(cursorPath string, c *sink.Cursor) error {
+	cursorString := c.String()
+
+	err := os.MkdirAll(cursorPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("making state store path: %w", err)
+	}
+
+	fpath := filepath.Join(cursorPath, cursorFilename)
+
+	err = os.WriteFile(fpath, []byte(cursorString), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("writing cursor file: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimFencingToken records token, the fencing token of a newly elected leader, in cursorPath.
+// Leaders holding a lower token can't save their cursor anymore, it fails with [ErrFenced] if a
+// higher token was already claimed. The claim waits for the cursor save in progress, if any.
+func ClaimFencingToken(cursorPath string, token uint64) error {
+	unlock, err := lockCursorPath(cursorPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := CheckFencingToken(cursorPath, token); err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(cursorPath, fencingTokenFilename), []byte(strconv.FormatUint(token, 10)), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("writing fencing token file: %w", err)
+	}
+
+	return nil
+}
+
+// CheckFencingToken returns [ErrFenced] if a fencing token higher than token was claimed in
+// cursorPath.
+func CheckFencingToken(cursorPath string, token uint64) error {
+	content, err := os.ReadFile(filepath.Join(cursorPath, fencingTokenFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading fencing token file: %w", err)
+	}
+
+	claimed, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("parsing fencing token: %w", err)
+	}
+
+	if claimed > token {
+		return fmt.Errorf("%w, fencing token %d is lower than %d", ErrFenced, token, claimed)
+	}
+
+	return nil
+}
+
+// DeleteCursor removes the cursor saved in cursorPath, the sink then restarts from the
+// beginning of its block range.
+func DeleteCursor(cursorPath string) error {
+	err := os.Remove(filepath.Join(cursorPath, cursorFilename))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing cursor file: %w", err)
+	}
+
+	return nil
+}
+
+// AppendCursorHistory records entry in the cursor history kept alongside the cursor in cursorPath.
+func AppendCursorHistory(cursorPath string, entry *CursorHistoryEntry) error {
+	err := os.MkdirAll(cursorPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("making state store path: %w", err)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding history entry: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(cursorPath, cursorHistoryFilename), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening cursor history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing cursor history file: %w", err)
+	}
+
+	return nil
+}
+
+// ReadCursorHistory returns the cursor history kept in cursorPath, oldest entry first.
+func ReadCursorHistory(cursorPath string) ([]*CursorHistoryEntry, error) {
+	file, err := os.Open(filepath.Join(cursorPath, cursorHistoryFilename))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening cursor history file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*CursorHistoryEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := &CursorHistoryEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("decoding cursor history entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading cursor history file: %w", err)
+	}
+
+	return entries, nil
+}
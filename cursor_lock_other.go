@@ -0,0 +1,9 @@
+//go:build !unix
+
+package substreams_sink_pubsub
+
+// lockCursorPath doesn't lock on this platform, the fencing token check and the cursor write
+// of different processes may interleave.
+func lockCursorPath(cursorPath string) (unlock func(), err error) {
+	return func() {}, nil
+}
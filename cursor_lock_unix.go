@@ -0,0 +1,33 @@
+//go:build unix
+
+package substreams_sink_pubsub
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockCursorPath takes an exclusive advisory lock on cursorPath, blocking until it's released
+// by other processes of the host, and returns the function releasing it.
+func lockCursorPath(cursorPath string) (unlock func(), err error) {
+	if err := os.MkdirAll(cursorPath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("making state store path: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(cursorPath, cursorLockFilename), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening cursor lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("locking cursor lock file: %w", err)
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
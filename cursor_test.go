@@ -0,0 +1,112 @@
+package substreams_sink_pubsub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/bstream"
+	sink "github.com/streamingfast/substreams-sink"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteCursor(t *testing.T) {
+	cursorPath := t.TempDir()
+	cursor := &sink.Cursor{Cursor: &bstream.Cursor{
+		Step:      bstream.StepNewIrreversible,
+		Block:     bstream.NewBlockRef("abc", 100),
+		LIB:       bstream.NewBlockRef("abc", 100),
+		HeadBlock: bstream.NewBlockRef("abc", 100),
+	}}
+
+	require.NoError(t, SaveCursor(cursorPath, cursor))
+	require.NoError(t, DeleteCursor(cursorPath))
+	require.NoError(t, DeleteCursor(cursorPath))
+
+	loaded, err := LoadCursor(cursorPath)
+	require.NoError(t, err)
+	require.Nil(t, loaded)
+}
+
+func TestCursorHistory(t *testing.T) {
+	cursorPath := t.TempDir()
+
+	entries, err := ReadCursorHistory(cursorPath)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	first := &CursorHistoryEntry{Time: time.Unix(1, 0).UTC(), Action: "set", Cursor: "c1"}
+	second := &CursorHistoryEntry{Time: time.Unix(2, 0).UTC(), Action: "reset", Previous: "c1"}
+	require.NoError(t, AppendCursorHistory(cursorPath, first))
+	require.NoError(t, AppendCursorHistory(cursorPath, second))
+
+	entries, err = ReadCursorHistory(cursorPath)
+	require.NoError(t, err)
+	require.Equal(t, []*CursorHistoryEntry{first, second}, entries)
+}
+
+func TestFencingToken(t *testing.T) {
+	cursorPath := t.TempDir()
+
+	require.NoError(t, CheckFencingToken(cursorPath, 1))
+	require.NoError(t, ClaimFencingToken(cursorPath, 2))
+	require.NoError(t, CheckFencingToken(cursorPath, 2))
+	require.NoError(t, ClaimFencingToken(cursorPath, 3))
+
+	require.ErrorIs(t, CheckFencingToken(cursorPath, 2), ErrFenced)
+	require.ErrorIs(t, ClaimFencingToken(cursorPath, 2), ErrFenced)
+
+	cursor := &sink.Cursor{Cursor: &bstream.Cursor{
+		Step:      bstream.StepNewIrreversible,
+		Block:     bstream.NewBlockRef("abc", 100),
+		LIB:       bstream.NewBlockRef("abc", 100),
+		HeadBlock: bstream.NewBlockRef("abc", 100),
+	}}
+
+	stale := &Sink{logger: logger, cursorStore: NewFileCursorStore(cursorPath)}
+	stale.SetFencingToken(2)
+	require.ErrorIs(t, stale.saveCursor(cursor), ErrFenced)
+
+	leader := &Sink{logger: logger, cursorStore: NewFileCursorStore(cursorPath)}
+	leader.SetFencingToken(3)
+	require.NoError(t, leader.saveCursor(cursor))
+}
+
+func TestFencingTokenLocked(t *testing.T) {
+	cursorPath := t.TempDir()
+	require.NoError(t, ClaimFencingToken(cursorPath, 2))
+
+	cursor := &sink.Cursor{Cursor: &bstream.Cursor{
+		Step:      bstream.StepNewIrreversible,
+		Block:     bstream.NewBlockRef("abc", 100),
+		LIB:       bstream.NewBlockRef("abc", 100),
+		HeadBlock: bstream.NewBlockRef("abc", 100),
+	}}
+
+	// A new leader claims its token while the stale leader's save is waiting for the lock
+	unlock, err := lockCursorPath(cursorPath)
+	require.NoError(t, err)
+
+	store := NewFileCursorStore(cursorPath)
+	store.SetFencingToken(2)
+	saved := make(chan error, 1)
+	go func() {
+		saved <- store.Save(cursor)
+	}()
+
+	select {
+	case err := <-saved:
+		t.Fatalf("save completed while the cursor path is locked: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(cursorPath, fencingTokenFilename), []byte("3"), 0644))
+	unlock()
+
+	require.ErrorIs(t, <-saved, ErrFenced)
+
+	loaded, err := LoadCursor(cursorPath)
+	require.NoError(t, err)
+	require.Nil(t, loaded)
+}
@@ -1,22 +1,24 @@
-#!/usr/bin/env bash
+#!/usr/bin/env sh
 
-set -euo pipefail
+set -eu
 
-emulator_addr="http://${PUBSUB_EMULATOR_HOST:-"localhost:8085"}"
+emulator_host="${PUBSUB_EMULATOR_HOST:-"localhost:8085"}"
 project="${PROJECT:-"acme"}"
 topic="${TOPIC:-"dev-topic"}"
 subscription="${SUBSCRIPTION:-"dev-topic-sub"}"
+sink="${SUBSTREAMS_SINK_PUBSUB:-"substreams-sink-pubsub"}"
 
-output=`curl -sS -X PUT "${emulator_addr}/v1/projects/${project}/topics/${topic}"`
-if [[ $output == *"\"code\""* ]]; then
-  if [[ $output != *"ALREADY_EXISTS"* ]]; then
-    echo $output
-    exit 1
+# create runs a 'tools' create command, succeeding if the resource already exists
+create() {
+  if ! output=`"$sink" tools "$@" --project "$project" --emulator "$emulator_host" 2>&1`; then
+    case "$output" in
+      *AlreadyExists*) echo "$1 $3 already exists" ;;
+      *) echo "$output"; exit 1 ;;
+    esac
+  else
+    echo "$output"
   fi
-fi
+}
 
-curl \
-    -X PUT \
-    -H "Content-Type: application/json" \
-    -d "{\"topic\": \"projects/${project}/topics/${topic}\"}" \
-    "${emulator_addr}/v1/projects/${project}/subscriptions/${subscription}"
+create topic create "$topic"
+create subscription create "$subscription" "$topic"
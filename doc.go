@@ -0,0 +1,32 @@
+// Package substreams_sink_pubsub publishes the output of a Substreams module to Google Cloud
+// PubSub, or to any [publisher.Publisher]. It's the library behind the 'substreams-sink-pubsub'
+// command, and can be embedded in a Go service:
+//
+//	s, err := substreams_sink_pubsub.New(
+//		substreams_sink_pubsub.WithSinker(sinker),
+//		substreams_sink_pubsub.WithPublisher(publisher.NewPubSub(client, topic)),
+//		substreams_sink_pubsub.WithCursorPath("./state"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//
+//	s.Run(ctx)
+//	return s.Err()
+//
+// The module's output must be a 'sf.substreams.sink.pubsub.v1.Publish' message, see the
+// 'pb/sf/substreams/sink/pubsub/v1' package. For each block, the sink generates the messages,
+// applies the filters and the transformer, publishes the messages and waits for their
+// acknowledgement, then calls the hooks and saves the cursor.
+//
+// # Stability
+//
+// The package follows semantic versioning. Within a major version, [New] and its options,
+// [Sink]'s exported methods, [CursorStore], [BlockContext], [Transformer], [Filter], [Hooks] and
+// the [publisher.Publisher] interface are not removed nor changed in a backward incompatible
+// way. New options, hooks and fields may be added in minor versions, so [Hooks] and
+// [BlockContext] must be created with field names. Until the first v1 tag, minor versions
+// may still break the API, with the change documented in the release notes. Identifiers
+// marked as deprecated, like [NewSink], are kept until the next major version. The messages'
+// attributes and the metrics' names are covered by the same guarantees.
+package substreams_sink_pubsub
@@ -0,0 +1,105 @@
+package substreams_sink_pubsub_test
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	sink "github.com/streamingfast/substreams-sink"
+	"go.uber.org/zap"
+
+	spubsub "github.com/streamingfast/substreams-sink-pubsub"
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+func ExampleNew() {
+	ctx := context.Background()
+
+	// Created with sink.New, or sink.NewFromViper for a cobra command using sink.AddFlagsToSet
+	var sinker *sink.Sinker
+
+	client, err := pubsub.NewClient(ctx, "acme")
+	if err != nil {
+		panic(err)
+	}
+
+	s, err := spubsub.New(
+		spubsub.WithSinker(sinker),
+		spubsub.WithPublisher(publisher.NewPubSub(client, client.Topic("transfers"))),
+		spubsub.WithLogger(zap.NewExample()),
+		spubsub.WithCursorPath("./state"),
+		spubsub.WithCheckpoint(100, 0),
+		spubsub.WithMetrics(prometheus.DefaultRegisterer),
+		spubsub.WithHooks(spubsub.Hooks{
+			OnCursorSaved: func(cursor *sink.Cursor) {
+				fmt.Println("saved", cursor.Block())
+			},
+		}),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	s.Run(ctx)
+	if err := s.Err(); err != nil {
+		panic(err)
+	}
+}
+
+func ExampleWithFilter() {
+	// Only publish the messages of final blocks having a 'Type=transfer' attribute
+	finalTransfers := spubsub.WithFilter(
+		func(block *spubsub.BlockContext, message *pubsub.Message) bool {
+			return block.Final
+		},
+		func(block *spubsub.BlockContext, message *pubsub.Message) bool {
+			return message.Attributes["Type"] == "transfer"
+		},
+	)
+
+	_ = finalTransfers
+}
+
+func ExampleWithTransformer() {
+	// Add the block's timestamp to every message, and drop empty ones
+	withTimestamp := spubsub.WithTransformer(func(ctx context.Context, block *spubsub.BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+		var out []*pubsub.Message
+		for _, message := range messages {
+			if len(strings.TrimSpace(string(message.Data))) == 0 {
+				continue
+			}
+
+			message.Attributes["BlockTimestamp"] = block.Timestamp.Format(time.RFC3339)
+			out = append(out, message)
+		}
+
+		return out, nil
+	})
+
+	_ = withTimestamp
+}
+
+func ExampleChain() {
+	prices := map[string]string{"USDC": "1.00"}
+
+	// Drop the approvals, add the block's number and the token's price, then compress
+	transformer := spubsub.Chain(
+		spubsub.FilterMessages(func(block *spubsub.BlockContext, message *pubsub.Message) bool {
+			return message.Attributes["Type"] != "approval"
+		}),
+		spubsub.BlockAttributes(),
+		spubsub.Enrich(func(ctx context.Context, block *spubsub.BlockContext, message *pubsub.Message) error {
+			message.Attributes["Price"] = prices[message.Attributes["Token"]]
+			return nil
+		}),
+		spubsub.RenameAttributes(map[string]string{"Token": "Symbol"}),
+		spubsub.Compress(gzip.BestSpeed),
+		spubsub.Chunk(1000*1000),
+	)
+
+	_ = spubsub.WithTransformer(transformer)
+}
@@ -22,4 +22,23 @@ pub struct Attribute {
     #[prost(string, tag="2")]
     pub value: ::prost::alloc::string::String,
 }
+/// BlockEnvelope holds all the messages of a block, published as a single PubSub message by the
+/// sink's '--envelope=block' mode.
+#[allow(clippy::derive_partial_eq_without_eq)]
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct BlockEnvelope {
+    #[prost(uint64, tag="1")]
+    pub block_number: u64,
+    #[prost(string, tag="2")]
+    pub block_id: ::prost::alloc::string::String,
+    #[prost(message, optional, tag="3")]
+    pub timestamp: ::core::option::Option<::prost_types::Timestamp>,
+    #[prost(string, tag="4")]
+    pub cursor: ::prost::alloc::string::String,
+    /// Step is 'New', or 'NewIrreversible' for a final block.
+    #[prost(string, tag="5")]
+    pub step: ::prost::alloc::string::String,
+    #[prost(message, repeated, tag="6")]
+    pub messages: ::prost::alloc::vec::Vec<Message>,
+}
 // @@protoc_insertion_point(module)
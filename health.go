@@ -0,0 +1,217 @@
+package substreams_sink_pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+// topicCheckInterval is how long the outcome of the destination check is reused by the
+// readiness probe, so that frequent probes don't hit the destination's API.
+const topicCheckInterval = 10 * time.Second
+
+// Health tracks the sink's state for Kubernetes readiness and liveness probes, see
+// [Health.Handler]. A nil Health is valid and tracks nothing.
+type Health struct {
+	blockTimeout     time.Duration
+	maxPublishErrors int64
+	checker          publisher.Checker
+
+	startedAt     atomic.Int64
+	standby       atomic.Bool
+	cursorLoaded  atomic.Bool
+	streaming     atomic.Bool
+	reconnecting  atomic.Bool
+	draining      atomic.Bool
+	lastBlockAt   atomic.Int64
+	publishErrors atomic.Int64
+
+	checkLock sync.Mutex
+	checkedAt time.Time
+	checkErr  error
+}
+
+// NewHealth creates the sink's health. Liveness fails when no block was handled for
+// blockTimeout or when more than maxPublishErrors messages failed to publish since the last
+// published block, zero disabling the check. The readiness probe checks the destination with
+// checker when not nil.
+func NewHealth(blockTimeout time.Duration, maxPublishErrors int, checker publisher.Checker) *Health {
+	h := &Health{
+		blockTimeout:     blockTimeout,
+		maxPublishErrors: int64(maxPublishErrors),
+		checker:          checker,
+	}
+	h.startedAt.Store(time.Now().UnixNano())
+
+	return h
+}
+
+// SetStandby marks the sink as a standby waiting for leadership, see [Elector]. A standby is
+// live but not ready, the block timeout starts once it leaves standby.
+func (h *Health) SetStandby(standby bool) {
+	if h == nil {
+		return
+	}
+
+	h.standby.Store(standby)
+	if !standby {
+		h.startedAt.Store(time.Now().UnixNano())
+	}
+}
+
+// RetryBackOff returns the back off of the Substreams stream's retries, to pass to the Sinker
+// with [sink.WithRetryBackOff]. It's the Sinker's default one, also reporting the sink not
+// ready from a stream error until the next block is handled.
+func (h *Health) RetryBackOff() backoff.BackOff {
+	backOff := backoff.NewExponentialBackOff()
+	backOff.MaxElapsedTime = 0
+
+	return &healthBackOff{BackOff: backOff, health: h}
+}
+
+// healthBackOff marks the stream as reconnecting each time the Sinker backs off after an error.
+type healthBackOff struct {
+	backoff.BackOff
+	health *Health
+}
+
+func (b *healthBackOff) NextBackOff() time.Duration {
+	if b.health != nil {
+		b.health.reconnecting.Store(true)
+	}
+
+	return b.BackOff.NextBackOff()
+}
+
+// Ready returns an error if the sink is not ready: the cursor was not loaded, no block was
+// received from the Substreams stream yet or since it failed, the destination is unreachable
+// or the sink is draining.
+func (h *Health) Ready(ctx context.Context) error {
+	if h.draining.Load() {
+		return fmt.Errorf("sink is draining")
+	}
+
+	if h.standby.Load() {
+		return fmt.Errorf("standby, waiting for leadership")
+	}
+
+	if !h.cursorLoaded.Load() {
+		return fmt.Errorf("cursor not loaded yet")
+	}
+
+	if !h.streaming.Load() {
+		return fmt.Errorf("no block received from the Substreams stream yet")
+	}
+
+	if h.reconnecting.Load() {
+		return fmt.Errorf("the Substreams stream failed, reconnecting")
+	}
+
+	if h.checker == nil {
+		return nil
+	}
+
+	h.checkLock.Lock()
+	defer h.checkLock.Unlock()
+
+	if h.checkedAt.IsZero() || time.Since(h.checkedAt) >= topicCheckInterval {
+		h.checkErr = h.checker.Check(ctx)
+		h.checkedAt = time.Now()
+	}
+
+	if h.checkErr != nil {
+		return fmt.Errorf("destination unreachable: %w", h.checkErr)
+	}
+
+	return nil
+}
+
+// Live returns an error if no block was handled within the block timeout or if too many
+// messages failed to publish.
+func (h *Health) Live() error {
+	if h.standby.Load() {
+		return nil
+	}
+
+	if h.blockTimeout > 0 {
+		lastBlockAt := time.Unix(0, h.startedAt.Load())
+		if value := h.lastBlockAt.Load(); value != 0 {
+			lastBlockAt = time.Unix(0, value)
+		}
+
+		if since := time.Since(lastBlockAt); since > h.blockTimeout {
+			return fmt.Errorf("no block handled for %s, more than %s", since.Truncate(time.Second), h.blockTimeout)
+		}
+	}
+
+	if h.maxPublishErrors > 0 {
+		if errors := h.publishErrors.Load(); errors > h.maxPublishErrors {
+			return fmt.Errorf("%d messages failed to publish since the last published block, more than %d", errors, h.maxPublishErrors)
+		}
+	}
+
+	return nil
+}
+
+// Handler serves the liveness probe on '/healthz' and the readiness probe on '/readyz',
+// answering 200 or 503 with a JSON body '{"status": "ok"|"error", "error": <reason>}'.
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, h.Live())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, h.Ready(r.Context()))
+	})
+
+	return mux
+}
+
+func writeProbe(w http.ResponseWriter, err error) {
+	body := map[string]string{"status": "ok"}
+	status := http.StatusOK
+	if err != nil {
+		body = map[string]string{"status": "error", "error": err.Error()}
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (h *Health) markCursorLoaded() {
+	if h != nil {
+		h.cursorLoaded.Store(true)
+	}
+}
+
+func (h *Health) markDraining() {
+	if h != nil {
+		h.draining.Store(true)
+	}
+}
+
+// markBlockHandled records a block or undo signal published and its cursor saved.
+func (h *Health) markBlockHandled() {
+	if h != nil {
+		h.streaming.Store(true)
+		h.reconnecting.Store(false)
+		h.lastBlockAt.Store(time.Now().UnixNano())
+		h.publishErrors.Store(0)
+	}
+}
+
+func (h *Health) markPublishErrors(count int) {
+	if h != nil && count > 0 {
+		h.publishErrors.Add(int64(count))
+	}
+}
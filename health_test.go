@@ -0,0 +1,97 @@
+package substreams_sink_pubsub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChecker struct {
+	err   error
+	calls int
+}
+
+func (c *fakeChecker) Check(_ context.Context) error {
+	c.calls++
+	return c.err
+}
+
+func TestHealthReady(t *testing.T) {
+	ctx := context.Background()
+	checker := &fakeChecker{}
+	health := NewHealth(0, 0, checker)
+
+	require.ErrorContains(t, health.Ready(ctx), "cursor not loaded")
+
+	health.markCursorLoaded()
+	require.ErrorContains(t, health.Ready(ctx), "no block received")
+
+	health.markBlockHandled()
+	require.NoError(t, health.Ready(ctx))
+	require.NoError(t, health.Ready(ctx))
+	require.Equal(t, 1, checker.calls, "destination check result is reused")
+
+	checker.err = fmt.Errorf("topic \"dev-topic\" does not exist")
+	health.checkedAt = time.Time{}
+	require.ErrorContains(t, health.Ready(ctx), "destination unreachable")
+
+	checker.err = nil
+	health.checkedAt = time.Time{}
+	require.NoError(t, health.Ready(ctx))
+
+	backOff := health.RetryBackOff()
+	require.Greater(t, backOff.NextBackOff(), time.Duration(0))
+	require.ErrorContains(t, health.Ready(ctx), "stream failed, reconnecting")
+
+	backOff.Reset()
+	require.ErrorContains(t, health.Ready(ctx), "stream failed, reconnecting")
+
+	health.markBlockHandled()
+	require.NoError(t, health.Ready(ctx))
+
+	health.markDraining()
+	require.ErrorContains(t, health.Ready(ctx), "draining")
+}
+
+func TestHealthLive(t *testing.T) {
+	health := NewHealth(0, 0, nil)
+	health.markPublishErrors(10)
+	require.NoError(t, health.Live())
+
+	health = NewHealth(50*time.Millisecond, 2, nil)
+	require.NoError(t, health.Live())
+
+	health.markPublishErrors(3)
+	require.ErrorContains(t, health.Live(), "3 messages failed to publish")
+
+	health.markBlockHandled()
+	require.NoError(t, health.Live())
+
+	time.Sleep(60 * time.Millisecond)
+	require.ErrorContains(t, health.Live(), "no block handled")
+}
+
+func TestHealthHandler(t *testing.T) {
+	health := NewHealth(0, 0, nil)
+	server := httptest.NewServer(health.Handler())
+	defer server.Close()
+
+	get := func(path string) int {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get("/healthz"))
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz"))
+
+	health.markCursorLoaded()
+	health.markBlockHandled()
+	require.Equal(t, http.StatusOK, get("/readyz"))
+}
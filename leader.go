@@ -0,0 +1,293 @@
+package substreams_sink_pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrLeaseHeld is returned by [LeaseStore.Acquire] when another holder has the lease.
+var ErrLeaseHeld = errors.New("lease held by another holder")
+
+// LeaseStore keeps a lease held by a single sink replica at a time, see [Elector].
+type LeaseStore interface {
+	// Acquire takes the lease for holder if it's free or expired, or renews it if holder already
+	// has it, returning [ErrLeaseHeld] otherwise. The returned fencing token is incremented each
+	// time the lease changes holder.
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (token uint64, err error)
+
+	// Release frees the lease if holder has it, so that a standby can take over immediately.
+	Release(ctx context.Context, holder string) error
+}
+
+// lease is the content of the lease object.
+type lease struct {
+	Holder    string    `json:"holder"`
+	Token     uint64    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// acquire returns the lease taken by holder from current, nil if there is none, or
+// [ErrLeaseHeld].
+func (l *lease) acquire(holder string, ttl time.Duration, now time.Time) (*lease, error) {
+	if l == nil {
+		return &lease{Holder: holder, Token: 1, ExpiresAt: now.Add(ttl)}, nil
+	}
+
+	if l.Holder == holder {
+		return &lease{Holder: holder, Token: l.Token, ExpiresAt: now.Add(ttl)}, nil
+	}
+
+	if now.Before(l.ExpiresAt) {
+		return nil, fmt.Errorf("%w %q until %s", ErrLeaseHeld, l.Holder, l.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return &lease{Holder: holder, Token: l.Token + 1, ExpiresAt: now.Add(ttl)}, nil
+}
+
+// GCSLeaseStore keeps the lease in a Google Cloud Storage object, concurrent updates are
+// detected with generation preconditions.
+type GCSLeaseStore struct {
+	client *storage.Client
+	object *storage.ObjectHandle
+}
+
+// NewGCSLeaseStore creates a store keeping the lease in the object of bucket. The store owns
+// client, which is closed along with it.
+func NewGCSLeaseStore(client *storage.Client, bucket, object string) *GCSLeaseStore {
+	return &GCSLeaseStore{client: client, object: client.Bucket(bucket).Object(object)}
+}
+
+func (s *GCSLeaseStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *GCSLeaseStore) Acquire(ctx context.Context, holder string, ttl time.Duration) (uint64, error) {
+	current, generation, err := s.read(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	next, err := current.acquire(holder, ttl, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.write(ctx, next, generation); err != nil {
+		return 0, err
+	}
+
+	return next.Token, nil
+}
+
+func (s *GCSLeaseStore) Release(ctx context.Context, holder string) error {
+	current, generation, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current == nil || current.Holder != holder {
+		return nil
+	}
+
+	current.ExpiresAt = time.Time{}
+	return s.write(ctx, current, generation)
+}
+
+// read returns the lease and its object's generation, nil and 0 if it doesn't exist.
+func (s *GCSLeaseStore) read(ctx context.Context) (*lease, int64, error) {
+	reader, err := s.object.NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading lease: %w", err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading lease: %w", err)
+	}
+
+	current := &lease{}
+	if err := json.Unmarshal(content, current); err != nil {
+		return nil, 0, fmt.Errorf("decoding lease: %w", err)
+	}
+
+	return current, reader.Attrs.Generation, nil
+}
+
+// write replaces the lease if its object is still at generation, 0 meaning it must not exist.
+func (s *GCSLeaseStore) write(ctx context.Context, l *lease, generation int64) error {
+	conditions := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		conditions = storage.Conditions{DoesNotExist: true}
+	}
+
+	content, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("encoding lease: %w", err)
+	}
+
+	writer := s.object.If(conditions).NewWriter(ctx)
+	writer.ContentType = "application/json"
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return fmt.Errorf("writing lease: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			return fmt.Errorf("%w, updated concurrently", ErrLeaseHeld)
+		}
+		return fmt.Errorf("writing lease: %w", err)
+	}
+
+	return nil
+}
+
+// Elector makes a sink replica the leader, the only one allowed to stream and save its cursor,
+// while the others wait as standbys.
+type Elector struct {
+	store  LeaseStore
+	holder string
+	ttl    time.Duration
+	logger *zap.Logger
+
+	lock    sync.Mutex
+	token   uint64
+	renewed time.Time
+}
+
+// NewElector creates an elector competing for the lease as holder, which must be unique across
+// replicas. The lease expires ttl after its last renewal, it's renewed every third of ttl.
+func NewElector(store LeaseStore, holder string, ttl time.Duration, logger *zap.Logger) *Elector {
+	return &Elector{
+		store:  store,
+		holder: holder,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// Campaign blocks until the replica is the leader or ctx is done, returning the lease's
+// fencing token.
+func (e *Elector) Campaign(ctx context.Context) (uint64, error) {
+	for {
+		startedAt := time.Now()
+		token, err := e.store.Acquire(ctx, e.holder, e.ttl)
+		if err == nil {
+			e.lock.Lock()
+			e.token, e.renewed = token, startedAt
+			e.lock.Unlock()
+
+			e.logger.Info("acquired leadership", zap.String("holder", e.holder), zap.Uint64("fencing_token", token))
+			return token, nil
+		}
+
+		if errors.Is(err, ErrLeaseHeld) {
+			e.logger.Debug("standing by, lease is held", zap.Error(err))
+		} else {
+			e.logger.Warn("acquiring lease failed, retrying", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(e.ttl / 3):
+		}
+	}
+}
+
+// Hold renews the lease until ctx is done. If the lease is taken by another holder, or cannot
+// be renewed within two thirds of the ttl since the last renewal, failed renewals being retried
+// until then, onLost is called and Hold returns. The remaining third of the ttl is left for the
+// sink to stop before a standby can take the lease.
+func (e *Elector) Hold(ctx context.Context, onLost func(err error)) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := e.renew(ctx); err != nil {
+			if ctx.Err() == nil {
+				onLost(err)
+			}
+			return
+		}
+	}
+}
+
+// renew renews the lease, retrying until two thirds of the ttl elapsed since the last renewal.
+func (e *Elector) renew(ctx context.Context) error {
+	e.lock.Lock()
+	heldToken := e.token
+	deadline := e.renewed.Add(e.ttl * 2 / 3)
+	e.lock.Unlock()
+
+	for {
+		startedAt := time.Now()
+		renewCtx, cancel := context.WithDeadline(ctx, deadline)
+		token, err := e.store.Acquire(renewCtx, e.holder, e.ttl)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err == nil && token == heldToken:
+			e.lock.Lock()
+			e.renewed = startedAt
+			e.lock.Unlock()
+			return nil
+		case err == nil:
+			return fmt.Errorf("lost leadership, lease fencing token changed from %d to %d", heldToken, token)
+		case errors.Is(err, ErrLeaseHeld):
+			return fmt.Errorf("lost leadership: %w", err)
+		case !time.Now().Before(deadline):
+			return fmt.Errorf("lost leadership, lease could not be renewed within %s: %w", e.ttl*2/3, err)
+		}
+
+		e.logger.Warn("renewing lease failed, retrying", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.ttl / 12):
+		}
+	}
+}
+
+// Close closes the lease store, if it's an [io.Closer].
+func (e *Elector) Close() error {
+	if closer, ok := e.store.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// Resign releases the lease so that a standby takes over without waiting for it to expire.
+func (e *Elector) Resign(ctx context.Context) error {
+	if err := e.store.Release(ctx, e.holder); err != nil {
+		return fmt.Errorf("releasing lease: %w", err)
+	}
+
+	e.logger.Info("resigned leadership", zap.String("holder", e.holder))
+	return nil
+}
@@ -0,0 +1,157 @@
+package substreams_sink_pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLeaseStore is a [LeaseStore] kept in memory.
+type memoryLeaseStore struct {
+	lock    sync.Mutex
+	current *lease
+}
+
+func (s *memoryLeaseStore) Acquire(_ context.Context, holder string, ttl time.Duration) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	next, err := s.current.acquire(holder, ttl, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	s.current = next
+	return next.Token, nil
+}
+
+func (s *memoryLeaseStore) Release(_ context.Context, holder string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current != nil && s.current.Holder == holder {
+		s.current.ExpiresAt = time.Time{}
+	}
+	return nil
+}
+
+func TestLeaseAcquire(t *testing.T) {
+	now := time.Now()
+	ttl := 10 * time.Second
+
+	tests := []struct {
+		name     string
+		current  *lease
+		holder   string
+		expected *lease
+		err      error
+	}{
+		{"free", nil, "a", &lease{Holder: "a", Token: 1, ExpiresAt: now.Add(ttl)}, nil},
+		{"renewed", &lease{Holder: "a", Token: 3, ExpiresAt: now.Add(time.Second)}, "a", &lease{Holder: "a", Token: 3, ExpiresAt: now.Add(ttl)}, nil},
+		{"held", &lease{Holder: "a", Token: 3, ExpiresAt: now.Add(time.Second)}, "b", nil, ErrLeaseHeld},
+		{"expired", &lease{Holder: "a", Token: 3, ExpiresAt: now.Add(-time.Second)}, "b", &lease{Holder: "b", Token: 4, ExpiresAt: now.Add(ttl)}, nil},
+		{"released", &lease{Holder: "a", Token: 3}, "b", &lease{Holder: "b", Token: 4, ExpiresAt: now.Add(ttl)}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next, err := test.current.acquire(test.holder, ttl, now)
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expected, next)
+		})
+	}
+}
+
+func TestElector(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryLeaseStore{}
+	ttl := 150 * time.Millisecond
+
+	leader := NewElector(store, "leader", ttl, logger)
+	standby := NewElector(store, "standby", ttl, logger)
+
+	token, err := leader.Campaign(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), token)
+
+	holdCtx, stopHolding := context.WithCancel(ctx)
+	go leader.Hold(holdCtx, func(err error) { t.Errorf("leader lost leadership: %s", err) })
+
+	campaignCtx, cancel := context.WithTimeout(ctx, 3*ttl)
+	_, err = standby.Campaign(campaignCtx)
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded, "standby must not take a renewed lease")
+
+	stopHolding()
+	require.NoError(t, leader.Resign(ctx))
+
+	token, err = standby.Campaign(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), token)
+
+	lost := make(chan error, 1)
+	go leader.Hold(ctx, func(err error) { lost <- err })
+
+	select {
+	case err := <-lost:
+		require.ErrorIs(t, err, ErrLeaseHeld)
+	case <-time.After(time.Second):
+		t.Fatal("stale leader did not detect the lost leadership")
+	}
+}
+
+// failingLeaseStore is a [LeaseStore] failing to acquire the lease once failing is set.
+type failingLeaseStore struct {
+	*memoryLeaseStore
+	failing atomic.Bool
+	closed  bool
+}
+
+func (s *failingLeaseStore) Acquire(ctx context.Context, holder string, ttl time.Duration) (uint64, error) {
+	if s.failing.Load() {
+		return 0, errors.New("store unavailable")
+	}
+
+	return s.memoryLeaseStore.Acquire(ctx, holder, ttl)
+}
+
+func (s *failingLeaseStore) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestElectorRenewalFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &failingLeaseStore{memoryLeaseStore: &memoryLeaseStore{}}
+	ttl := 300 * time.Millisecond
+
+	elector := NewElector(store, "leader", ttl, logger)
+	renewedAt := time.Now()
+	_, err := elector.Campaign(ctx)
+	require.NoError(t, err)
+
+	store.failing.Store(true)
+	lost := make(chan error, 1)
+	go elector.Hold(ctx, func(err error) { lost <- err })
+
+	select {
+	case err := <-lost:
+		require.ErrorContains(t, err, "lease could not be renewed within 200ms: store unavailable")
+		require.Less(t, time.Since(renewedAt), ttl, "leadership must be given up before the lease expires")
+	case <-time.After(time.Second):
+		t.Fatal("leader did not give up leadership")
+	}
+
+	require.NoError(t, elector.Close())
+	require.True(t, store.closed)
+}
@@ -0,0 +1,77 @@
+package substreams_sink_pubsub
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics are the sink's Prometheus metrics, see [WithMetrics]. A nil metrics is valid and
+// records nothing.
+type metrics struct {
+	blocks        prometheus.Counter
+	undoSignals   prometheus.Counter
+	published     prometheus.Counter
+	publishErrors prometheus.Counter
+	cursorSaves   prometheus.Counter
+	headBlock     prometheus.Gauge
+	cursorBlock   prometheus.Gauge
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		blocks:        newCounter("blocks_total", "Number of blocks handled"),
+		undoSignals:   newCounter("undo_signals_total", "Number of undo signals handled"),
+		published:     newCounter("messages_published_total", "Number of messages acknowledged by the destination"),
+		publishErrors: newCounter("publish_errors_total", "Number of messages that failed to publish"),
+		cursorSaves:   newCounter("cursor_saves_total", "Number of times the cursor was saved"),
+		headBlock:     newGauge("head_block_number", "Number of the last handled block"),
+		cursorBlock:   newGauge("cursor_block_number", "Number of the block of the last saved cursor"),
+	}
+
+	for _, collector := range []prometheus.Collector{m.blocks, m.undoSignals, m.published, m.publishErrors, m.cursorSaves, m.headBlock, m.cursorBlock} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func newCounter(name, help string) prometheus.Counter {
+	return prometheus.NewCounter(prometheus.CounterOpts{Namespace: "substreams_sink_pubsub", Name: name, Help: help})
+}
+
+func newGauge(name, help string) prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "substreams_sink_pubsub", Name: name, Help: help})
+}
+
+func (m *metrics) markBlockHandled(blockNum uint64) {
+	if m != nil {
+		m.blocks.Inc()
+		m.headBlock.Set(float64(blockNum))
+	}
+}
+
+func (m *metrics) markUndo() {
+	if m != nil {
+		m.undoSignals.Inc()
+	}
+}
+
+func (m *metrics) markPublished() {
+	if m != nil {
+		m.published.Inc()
+	}
+}
+
+func (m *metrics) markPublishError() {
+	if m != nil {
+		m.publishErrors.Inc()
+	}
+}
+
+func (m *metrics) markCursorSaved(blockNum uint64) {
+	if m != nil {
+		m.cursorSaves.Inc()
+		m.cursorBlock.Set(float64(blockNum))
+	}
+}
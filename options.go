@@ -0,0 +1,192 @@
+package substreams_sink_pubsub
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/streamingfast/bstream"
+	sink "github.com/streamingfast/substreams-sink"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+// Option configures a [Sink] created with [New].
+type Option func(s *Sink)
+
+// BlockContext describes the block whose messages are being published.
+type BlockContext struct {
+	Number    uint64
+	ID        string
+	Timestamp time.Time
+	Cursor    *sink.Cursor
+
+	// Final is true when the block is irreversible.
+	Final bool
+
+	// Live is true once the stream reached the chain's head.
+	Live bool
+}
+
+func newBlockContext(clock *pbsubstreams.Clock, cursor *sink.Cursor, isLive *bool) *BlockContext {
+	return &BlockContext{
+		Number:    clock.Number,
+		ID:        clock.Id,
+		Timestamp: clock.Timestamp.AsTime(),
+		Cursor:    cursor,
+		Final:     cursor.Step.Matches(bstream.StepIrreversible),
+		Live:      isLive != nil && *isLive,
+	}
+}
+
+// Hooks are called by the sink as it progresses, nil hooks are skipped. Hooks are called
+// synchronously and must return quickly.
+type Hooks struct {
+	// OnBlock is called once the block's messages are acknowledged, before its cursor is saved.
+	OnBlock func(ctx context.Context, block *BlockContext, messages []*pubsub.Message)
+
+	// OnUndo is called once the undo signal of the blocks after lastValidBlock is acknowledged.
+	OnUndo func(ctx context.Context, lastValidBlock uint64, cursor *sink.Cursor)
+
+	// OnCursorSaved is called after the cursor is saved.
+	OnCursorSaved func(cursor *sink.Cursor)
+
+	// OnPublishError is called for each message that failed to publish, concurrently.
+	OnPublishError func(message *pubsub.Message, err error)
+}
+
+// WithSinker sets the Substreams sinker streaming the module's output, required.
+func WithSinker(sinker *sink.Sinker) Option {
+	return func(s *Sink) {
+		s.Sinker = sinker
+	}
+}
+
+// WithPublisher sets where messages are published, required.
+func WithPublisher(publisher publisher.Publisher) Option {
+	return func(s *Sink) {
+		s.publisher = publisher
+	}
+}
+
+// WithLogger sets the sink's logger, nothing is logged by default.
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Sink) {
+		s.logger = logger
+	}
+}
+
+// WithCursorStore sets where the cursor is loaded from on startup and saved to.
+func WithCursorStore(store CursorStore) Option {
+	return func(s *Sink) {
+		s.cursorStore = store
+	}
+}
+
+// WithCursorPath keeps the cursor in the path directory, see [NewFileCursorStore].
+func WithCursorPath(path string) Option {
+	return WithCursorStore(NewFileCursorStore(path))
+}
+
+// WithDryRun processes the stream without loading nor saving the cursor, so that the sink
+// processes the whole block range and leaves no trace of its progress. The publisher is
+// expected to publish nothing, see [publisher.NewDryRun].
+func WithDryRun() Option {
+	return func(s *Sink) {
+		s.dryRun = true
+	}
+}
+
+// WithAttributes adds the attributes to every message, see [Sink.SetAttributes].
+func WithAttributes(attributes map[string]string) Option {
+	return func(s *Sink) {
+		s.SetAttributes(attributes)
+	}
+}
+
+// WithMessageIDSuffix appends suffix to the ID of every message, see [Sink.SetMessageIDSuffix].
+func WithMessageIDSuffix(suffix string) Option {
+	return func(s *Sink) {
+		s.SetMessageIDSuffix(suffix)
+	}
+}
+
+// WithBlockEnvelope publishes the messages of a block in a single envelope, see
+// [Sink.SetBlockEnvelope].
+func WithBlockEnvelope() Option {
+	return func(s *Sink) {
+		s.SetBlockEnvelope(true)
+	}
+}
+
+// WithBlockEndMarker publishes a marker after the messages of a block, see
+// [Sink.SetBlockEndMarker].
+func WithBlockEndMarker() Option {
+	return func(s *Sink) {
+		s.SetBlockEndMarker(true)
+	}
+}
+
+// WithHeartbeatInterval publishes heartbeats, see [Sink.SetHeartbeatInterval].
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.SetHeartbeatInterval(interval)
+	}
+}
+
+// WithCheckpoint batches the cursor saves, see [Sink.SetCheckpoint].
+func WithCheckpoint(blocks int, interval time.Duration) Option {
+	return func(s *Sink) {
+		s.SetCheckpoint(blocks, interval)
+	}
+}
+
+// WithDrainTimeout sets the time given to in-flight messages when the sink stops, see
+// [Sink.SetDrainTimeout].
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(s *Sink) {
+		s.SetDrainTimeout(timeout)
+	}
+}
+
+// WithHealth makes the sink report its state to health, see [Sink.SetHealth].
+func WithHealth(health *Health) Option {
+	return func(s *Sink) {
+		s.SetHealth(health)
+	}
+}
+
+// WithTransformer adds transformers applied to the messages of each block, after the filters
+// and in the order they are added, see [Chain]. With [WithBlockEnvelope], they receive the
+// envelope's chunks.
+func WithTransformer(transformers ...Transformer) Option {
+	return func(s *Sink) {
+		s.transformer = Chain(append([]Transformer{s.transformer}, transformers...)...)
+	}
+}
+
+// WithFilter adds filters, a message is published only if every filter keeps it. With
+// [WithBlockEnvelope], filters receive the envelope's chunks.
+func WithFilter(filters ...Filter) Option {
+	return func(s *Sink) {
+		s.filters = append(s.filters, filters...)
+	}
+}
+
+// WithHooks sets the hooks called as the sink progresses.
+func WithHooks(hooks Hooks) Option {
+	return func(s *Sink) {
+		s.hooks = hooks
+	}
+}
+
+// WithMetrics registers the sink's Prometheus metrics, prefixed with 'substreams_sink_pubsub_',
+// in registerer. [New] fails if they are already registered.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(s *Sink) {
+		s.registerer = registerer
+	}
+}
@@ -0,0 +1,160 @@
+package substreams_sink_pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streamingfast/bstream"
+	sink "github.com/streamingfast/substreams-sink"
+	pbsubstreamsrpc "github.com/streamingfast/substreams/pb/sf/substreams/rpc/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pbpubsub "github.com/streamingfast/substreams-sink-pubsub/pb/sf/substreams/sink/pubsub/v1"
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(WithPublisher(publisher.NewDryRun()), WithCursorPath(t.TempDir()))
+	require.ErrorContains(t, err, "sinker is required")
+
+	_, err = New(WithSinker(&sink.Sinker{}), WithCursorPath(t.TempDir()))
+	require.ErrorContains(t, err, "publisher is required")
+
+	_, err = New(WithSinker(&sink.Sinker{}), WithPublisher(publisher.NewDryRun()))
+	require.ErrorContains(t, err, "cursor store is required")
+
+	_, err = New(WithSinker(&sink.Sinker{}), WithPublisher(publisher.NewDryRun()), WithDryRun())
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	_, err = New(WithSinker(&sink.Sinker{}), WithPublisher(publisher.NewDryRun()), WithDryRun(), WithMetrics(registry))
+	require.NoError(t, err)
+	_, err = New(WithSinker(&sink.Sinker{}), WithPublisher(publisher.NewDryRun()), WithDryRun(), WithMetrics(registry))
+	require.ErrorContains(t, err, "registering metrics")
+}
+
+func TestSinkOptions(t *testing.T) {
+	ctx := context.Background()
+	cursorPath := t.TempDir()
+
+	var handled []string
+	var saved *sink.Cursor
+
+	s, err := New(
+		WithSinker(&sink.Sinker{}),
+		WithPublisher(publisher.NewDryRun()),
+		WithLogger(logger),
+		WithCursorPath(cursorPath),
+		WithMetrics(prometheus.NewRegistry()),
+		WithFilter(func(block *BlockContext, message *pubsub.Message) bool {
+			return message.Attributes["skip"] != "true"
+		}),
+		WithTransformer(func(ctx context.Context, block *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+			for _, message := range messages {
+				message.Attributes["Final"] = "false"
+				if block.Final {
+					message.Attributes["Final"] = "true"
+				}
+			}
+			return messages, nil
+		}),
+		WithHooks(Hooks{
+			OnBlock: func(ctx context.Context, block *BlockContext, messages []*pubsub.Message) {
+				for _, message := range messages {
+					handled = append(handled, string(message.Data)+"/"+message.Attributes["Final"])
+				}
+			},
+			OnCursorSaved: func(cursor *sink.Cursor) {
+				saved = cursor
+			},
+		}),
+	)
+	require.NoError(t, err)
+
+	output, err := anypb.New(&pbpubsub.Publish{
+		Messages: []*pbpubsub.Message{
+			{Data: []byte("kept")},
+			{Data: []byte("skipped"), Attributes: []*pbpubsub.Attribute{{Key: "skip", Value: "true"}}},
+		},
+	})
+	require.NoError(t, err)
+
+	block := bstream.NewBlockRef("4a", 4)
+	cursor := &sink.Cursor{Cursor: &bstream.Cursor{Step: bstream.StepNewIrreversible, Block: block, LIB: block, HeadBlock: block}}
+	data := &pbsubstreamsrpc.BlockScopedData{
+		Output: &pbsubstreamsrpc.MapModuleOutput{MapOutput: output},
+		Clock:  &pbsubstreams.Clock{Number: 4, Id: "4a", Timestamp: timestamppb.New(time.Now())},
+	}
+
+	live := false
+	require.NoError(t, s.handleBlockScopedData(ctx, data, &live, cursor))
+
+	require.Equal(t, []string{"kept/true"}, handled)
+	require.Equal(t, cursor, saved)
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.blocks))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.published))
+	require.Equal(t, 4.0, testutil.ToFloat64(s.metrics.cursorBlock))
+}
+
+func TestHeartbeatDryRun(t *testing.T) {
+	ctx := context.Background()
+	dryRun := publisher.NewDryRun()
+
+	s, err := New(WithSinker(&sink.Sinker{}), WithPublisher(dryRun), WithLogger(logger), WithDryRun(), WithHeartbeatInterval(time.Minute))
+	require.NoError(t, err)
+
+	output, err := anypb.New(&pbpubsub.Publish{})
+	require.NoError(t, err)
+
+	handle := func(blockNum uint64) {
+		block := bstream.NewBlockRef(fmt.Sprintf("%da", blockNum), blockNum)
+		cursor := &sink.Cursor{Cursor: &bstream.Cursor{Step: bstream.StepNew, Block: block, LIB: block, HeadBlock: block}}
+		data := &pbsubstreamsrpc.BlockScopedData{
+			Output: &pbsubstreamsrpc.MapModuleOutput{MapOutput: output},
+			Clock:  &pbsubstreams.Clock{Number: blockNum, Id: block.ID(), Timestamp: timestamppb.New(time.Now())},
+		}
+		require.NoError(t, s.handleBlockScopedData(ctx, data, nil, cursor))
+	}
+
+	handle(1)
+	s.lastPublishAt = time.Now().Add(-2 * time.Minute)
+	handle(2)
+	handle(3)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, dryRun.WriteSummary(out))
+	require.Regexp(t, `Blocks\s+3\n`, out.String())
+	require.Regexp(t, `Heartbeats\s+1\n`, out.String())
+	require.Regexp(t, `Messages\s+0\n`, out.String())
+}
+
+func TestMessageIDSuffix(t *testing.T) {
+	out := &bytes.Buffer{}
+	s, err := New(WithSinker(&sink.Sinker{}), WithPublisher(publisher.NewWriterFile(out)), WithDryRun(), WithMessageIDSuffix("-replay"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.publishMessages(context.Background(), []*pubsub.Message{
+		{ID: "4-4a-0"},
+		{ID: "4-4a-1-replay"},
+		{Attributes: map[string]string{"Step": "Heartbeat"}},
+	}))
+
+	var ids []string
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		record := &publisher.FileRecord{}
+		require.NoError(t, decoder.Decode(record))
+		ids = append(ids, record.ID)
+	}
+	require.Equal(t, []string{"4-4a-0-replay", "4-4a-1-replay", ""}, ids)
+}
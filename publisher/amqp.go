@@ -0,0 +1,206 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPConfig defines where [AMQP] routes each message, the templates are rendered against
+// each message (see [Template]).
+type AMQPConfig struct {
+	// Exchange is the exchange messages are published to.
+	Exchange *Template
+
+	// RoutingKey is the routing key of block messages.
+	RoutingKey *Template
+
+	// UndoRoutingKey is the routing key of undo messages (attribute 'Step' equal to 'Undo'),
+	// when nil, RoutingKey is used.
+	UndoRoutingKey *Template
+}
+
+// AMQP publishes messages to an AMQP 0.9.1 broker like RabbitMQ. Attributes become the
+// message's headers, the message's ID its 'message-id' property and messages are sent
+// persistent.
+//
+// The channel is put in confirm mode and a message's [Result] resolves only once the broker
+// has confirmed it, giving the same at-least-once guarantees as PubSub. Messages are published
+// without the 'mandatory' flag: the exchange must exist and be bound to a queue matching every
+// routing key, a message no queue is bound for is confirmed as a success and dropped by the
+// broker.
+type AMQP struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	config  AMQPConfig
+
+	// pending holds the confirmations not received yet, in publishing order.
+	pending []amqpConfirmation
+
+	closeTimeout time.Duration
+}
+
+// AMQPCloseTimeout is the time [AMQP.Close] waits for the confirmation of the messages in
+// flight before closing the channel and the connection.
+const AMQPCloseTimeout = 30 * time.Second
+
+// amqpConfirmation is the subset of [amqp.DeferredConfirmation] used to track pending messages.
+type amqpConfirmation interface {
+	Done() <-chan struct{}
+	WaitContext(ctx context.Context) (bool, error)
+}
+
+func NewAMQP(url string, config AMQPConfig) (*AMQP, error) {
+	if config.Exchange == nil || config.RoutingKey == nil {
+		return nil, fmt.Errorf("exchange and routing key templates are required")
+	}
+
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to amqp broker: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("opening amqp channel: %w", err)
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("enabling publisher confirms: %w", err)
+	}
+
+	return &AMQP{
+		conn:         conn,
+		channel:      channel,
+		config:       config,
+		closeTimeout: AMQPCloseTimeout,
+	}, nil
+}
+
+func (p *AMQP) Publish(ctx context.Context, messages []*pubsub.Message) []Result {
+	p.pending = trimConfirmed(p.pending)
+
+	results := make([]Result, 0, len(messages))
+	for _, message := range messages {
+		exchange, routingKey, err := p.config.route(message)
+		if err != nil {
+			results = append(results, resolvedResult("", err))
+			continue
+		}
+
+		confirmation, err := p.channel.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, amqpPublishing(message))
+		if err != nil {
+			results = append(results, resolvedResult("", fmt.Errorf("publishing to exchange %q: %w", exchange, err)))
+			continue
+		}
+
+		p.pending = append(p.pending, confirmation)
+		results = append(results, &amqpResult{confirmation: confirmation, exchange: exchange, routingKey: routingKey})
+	}
+
+	return results
+}
+
+func (p *AMQP) Flush(ctx context.Context) error {
+	for _, confirmation := range p.pending {
+		if _, err := confirmation.WaitContext(ctx); err != nil {
+			return err
+		}
+	}
+	p.pending = nil
+
+	return nil
+}
+
+// Close waits up to [AMQPCloseTimeout] for the pending messages to be confirmed, then closes the
+// channel and the connection even if the wait failed or timed out.
+func (p *AMQP) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.closeTimeout)
+	defer cancel()
+
+	flushErr := p.Flush(ctx)
+	if flushErr != nil {
+		flushErr = fmt.Errorf("waiting for %d messages in flight: %w", len(trimConfirmed(p.pending)), flushErr)
+	}
+
+	return errors.Join(flushErr, p.channel.Close(), p.conn.Close())
+}
+
+// trimConfirmed drops the leading confirmations already received, so that pending only grows
+// with the messages in flight.
+func trimConfirmed(pending []amqpConfirmation) []amqpConfirmation {
+	confirmed := 0
+	for confirmed < len(pending) && isDone(pending[confirmed].Done()) {
+		confirmed++
+	}
+
+	return append(pending[:0], pending[confirmed:]...)
+}
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c AMQPConfig) route(message *pubsub.Message) (exchange string, routingKey string, err error) {
+	exchange, err = c.Exchange.Render(message)
+	if err != nil {
+		return "", "", fmt.Errorf("rendering exchange: %w", err)
+	}
+
+	routingKeyTemplate := c.RoutingKey
+	if c.UndoRoutingKey != nil && message.Attributes["Step"] == "Undo" {
+		routingKeyTemplate = c.UndoRoutingKey
+	}
+
+	routingKey, err = routingKeyTemplate.Render(message)
+	if err != nil {
+		return "", "", fmt.Errorf("rendering routing key: %w", err)
+	}
+
+	return exchange, routingKey, nil
+}
+
+func amqpPublishing(message *pubsub.Message) amqp.Publishing {
+	headers := make(amqp.Table, len(message.Attributes))
+	for key, value := range message.Attributes {
+		headers[key] = value
+	}
+
+	return amqp.Publishing{
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    message.ID,
+		Body:         message.Data,
+	}
+}
+
+type amqpResult struct {
+	confirmation *amqp.DeferredConfirmation
+	exchange     string
+	routingKey   string
+}
+
+func (r *amqpResult) Get(ctx context.Context) (string, error) {
+	acked, err := r.confirmation.WaitContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if !acked {
+		return "", fmt.Errorf("message to exchange %q with routing key %q was not confirmed by the broker", r.exchange, r.routingKey)
+	}
+
+	return strconv.FormatUint(r.confirmation.DeliveryTag, 10), nil
+}
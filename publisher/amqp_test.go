@@ -0,0 +1,97 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAMQPRoute(t *testing.T) {
+	cursor := testCursor(4, "4a")
+
+	config := AMQPConfig{
+		Exchange:       NewTemplate("chain"),
+		RoutingKey:     NewTemplate("blocks.{{BlockNumber}}"),
+		UndoRoutingKey: NewTemplate("undo"),
+	}
+
+	exchange, routingKey, err := config.route(&pubsub.Message{Attributes: map[string]string{"Cursor": cursor}})
+	require.NoError(t, err)
+	require.Equal(t, "chain", exchange)
+	require.Equal(t, "blocks.4", routingKey)
+
+	exchange, routingKey, err = config.route(&pubsub.Message{Attributes: map[string]string{"Cursor": cursor, "Step": "Undo"}})
+	require.NoError(t, err)
+	require.Equal(t, "chain", exchange)
+	require.Equal(t, "undo", routingKey)
+
+	config.UndoRoutingKey = nil
+	_, routingKey, err = config.route(&pubsub.Message{Attributes: map[string]string{"Cursor": cursor, "Step": "Undo"}})
+	require.NoError(t, err)
+	require.Equal(t, "blocks.4", routingKey)
+}
+
+func TestAMQPPublishing(t *testing.T) {
+	publishing := amqpPublishing(&pubsub.Message{
+		ID:         "4-4a-0",
+		Data:       []byte("data.1"),
+		Attributes: map[string]string{"Cursor": "c1", "key1": "value1"},
+	})
+
+	require.Equal(t, amqp.Publishing{
+		Headers:      amqp.Table{"Cursor": "c1", "key1": "value1"},
+		DeliveryMode: amqp.Persistent,
+		MessageId:    "4-4a-0",
+		Body:         []byte("data.1"),
+	}, publishing)
+}
+
+type fakeConfirmation struct {
+	done chan struct{}
+}
+
+func (c *fakeConfirmation) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *fakeConfirmation) WaitContext(ctx context.Context) (bool, error) {
+	select {
+	case <-c.done:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+func TestAMQPTrimConfirmed(t *testing.T) {
+	confirmed := func() *fakeConfirmation {
+		c := &fakeConfirmation{done: make(chan struct{})}
+		close(c.done)
+		return c
+	}
+	inFlight := &fakeConfirmation{done: make(chan struct{})}
+	last := confirmed()
+
+	pending := []amqpConfirmation{confirmed(), confirmed(), inFlight, last}
+	require.Equal(t, []amqpConfirmation{inFlight, last}, trimConfirmed(pending))
+
+	close(inFlight.done)
+	require.Empty(t, trimConfirmed([]amqpConfirmation{inFlight, last}))
+	require.Empty(t, trimConfirmed(nil))
+}
+
+func TestAMQPFlushDeadline(t *testing.T) {
+	confirmed := &fakeConfirmation{done: make(chan struct{})}
+	close(confirmed.done)
+	p := &AMQP{pending: []amqpConfirmation{confirmed, &fakeConfirmation{done: make(chan struct{})}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, p.Flush(ctx), context.DeadlineExceeded)
+	require.Len(t, trimConfirmed(p.pending), 1)
+}
@@ -0,0 +1,207 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"cloud.google.com/go/pubsub"
+)
+
+const (
+	dryRunSizeSamples           = 100_000
+	dryRunMaxAttributeValues    = 10_000
+	dryRunLargestMessages       = 10
+	dryRunInvalidMessageSamples = 10
+)
+
+// DryRun is a [Publisher] that publishes nothing. It validates messages against PubSub limits
+// (see [ValidatePubSubMessage]) and gathers statistics about them, written out by
+// [DryRun.WriteSummary]. Invalid messages are reported in the summary and do not fail.
+type DryRun struct {
+	lock sync.Mutex
+
+	blocks          uint64
+	undos           uint64
+	blockEndMarkers uint64
+	heartbeats      uint64
+	messages        uint64
+	totalSize       uint64
+	sizeSamples     []int
+	attributeValues map[string]map[string]struct{}
+	largest         []dryRunMessage
+	invalid         uint64
+	invalidSamples  []dryRunMessage
+}
+
+type dryRunMessage struct {
+	id          string
+	blockNumber uint64
+	size        int
+	err         error
+}
+
+func NewDryRun() *DryRun {
+	return &DryRun{
+		attributeValues: map[string]map[string]struct{}{},
+	}
+}
+
+func (p *DryRun) Publish(_ context.Context, messages []*pubsub.Message) []Result {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// Block end markers and heartbeats are published on their own after a block's messages,
+	// they are neither a block nor one of its messages
+	var control int
+	results := make([]Result, 0, len(messages))
+	for _, message := range messages {
+		switch message.Attributes["Step"] {
+		case "BlockEnd":
+			p.blockEndMarkers++
+			control++
+		case "Heartbeat":
+			p.heartbeats++
+			control++
+		default:
+			p.record(message)
+		}
+		results = append(results, resolvedResult(message.ID, nil))
+	}
+
+	switch {
+	case len(messages) == 1 && messages[0].Attributes["Step"] == "Undo":
+		p.undos++
+	case len(messages) > 0 && control == len(messages):
+	default:
+		p.blocks++
+	}
+
+	return results
+}
+
+func (p *DryRun) record(message *pubsub.Message) {
+	summary := dryRunMessage{id: message.ID, size: MessageSize(message)}
+	if summary.id == "" {
+		summary.id = "-"
+	}
+	if cursor := messageCursor(message); cursor != nil {
+		summary.blockNumber = cursor.Block().Num()
+	}
+
+	p.messages++
+	p.totalSize += uint64(summary.size)
+
+	// Reservoir sampling keeps percentiles accurate enough with a bounded memory usage
+	if len(p.sizeSamples) < dryRunSizeSamples {
+		p.sizeSamples = append(p.sizeSamples, summary.size)
+	} else if i := rand.Int63n(int64(p.messages)); i < dryRunSizeSamples {
+		p.sizeSamples[i] = summary.size
+	}
+
+	for key, value := range message.Attributes {
+		values, found := p.attributeValues[key]
+		if !found {
+			values = map[string]struct{}{}
+			p.attributeValues[key] = values
+		}
+		if len(values) < dryRunMaxAttributeValues {
+			values[value] = struct{}{}
+		}
+	}
+
+	if len(p.largest) < dryRunLargestMessages || summary.size > p.largest[len(p.largest)-1].size {
+		p.largest = append(p.largest, summary)
+		sort.SliceStable(p.largest, func(i, j int) bool { return p.largest[i].size > p.largest[j].size })
+		if len(p.largest) > dryRunLargestMessages {
+			p.largest = p.largest[:dryRunLargestMessages]
+		}
+	}
+
+	if err := ValidatePubSubMessage(message); err != nil {
+		p.invalid++
+		if len(p.invalidSamples) < dryRunInvalidMessageSamples {
+			summary.err = err
+			p.invalidSamples = append(p.invalidSamples, summary)
+		}
+	}
+}
+
+func (p *DryRun) Flush(_ context.Context) error {
+	return nil
+}
+
+func (p *DryRun) Close() error {
+	return nil
+}
+
+// WriteSummary writes a human readable summary of the messages that would have been published.
+func (p *DryRun) WriteSummary(out io.Writer) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Dry run summary\n\n")
+	fmt.Fprintf(w, "Blocks\t%d\n", p.blocks)
+	fmt.Fprintf(w, "Undo signals\t%d\n", p.undos)
+	if p.blockEndMarkers > 0 {
+		fmt.Fprintf(w, "Block end markers\t%d\n", p.blockEndMarkers)
+	}
+	if p.heartbeats > 0 {
+		fmt.Fprintf(w, "Heartbeats\t%d\n", p.heartbeats)
+	}
+	fmt.Fprintf(w, "Messages\t%d\n", p.messages)
+	fmt.Fprintf(w, "Total size\t%d bytes\n", p.totalSize)
+	fmt.Fprintf(w, "Invalid messages\t%d\n", p.invalid)
+
+	if len(p.sizeSamples) > 0 {
+		sizes := append([]int(nil), p.sizeSamples...)
+		sort.Ints(sizes)
+
+		fmt.Fprintf(w, "\nMessage size (bytes)\n")
+		for _, percentile := range []float64{50, 90, 99} {
+			fmt.Fprintf(w, "  p%.0f\t%d\n", percentile, sizes[int(float64(len(sizes)-1)*percentile/100)])
+		}
+		fmt.Fprintf(w, "  max\t%d\n", p.largest[0].size)
+	}
+
+	if len(p.attributeValues) > 0 {
+		fmt.Fprintf(w, "\nAttribute key cardinality (distinct values)\n")
+		keys := make([]string, 0, len(p.attributeValues))
+		for key := range p.attributeValues {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			count := len(p.attributeValues[key])
+			if count >= dryRunMaxAttributeValues {
+				fmt.Fprintf(w, "  %s\t>= %d\n", key, count)
+			} else {
+				fmt.Fprintf(w, "  %s\t%d\n", key, count)
+			}
+		}
+	}
+
+	if len(p.largest) > 0 {
+		fmt.Fprintf(w, "\nLargest messages\n")
+		for _, message := range p.largest {
+			fmt.Fprintf(w, "  #%d\t%s\t%d bytes\n", message.blockNumber, message.id, message.size)
+		}
+	}
+
+	if len(p.invalidSamples) > 0 {
+		fmt.Fprintf(w, "\nInvalid messages (first %d)\n", len(p.invalidSamples))
+		for _, message := range p.invalidSamples {
+			fmt.Fprintf(w, "  #%d\t%s\t%s\n", message.blockNumber, message.id, strings.ReplaceAll(message.err.Error(), "\t", " "))
+		}
+	}
+
+	return w.Flush()
+}
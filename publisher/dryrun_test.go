@@ -0,0 +1,96 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunSummary(t *testing.T) {
+	ctx := context.Background()
+	cursor := testCursor(4, "4a")
+
+	publisher := NewDryRun()
+	publisher.Publish(ctx, []*pubsub.Message{
+		{ID: "4-4a-0", Data: []byte("d"), Attributes: map[string]string{"Cursor": cursor, "kind": "a"}},
+		{ID: "4-4a-1", Data: []byte("dddddddddd"), Attributes: map[string]string{"Cursor": cursor, "kind": "b"}},
+		{ID: "4-4a-2", Data: []byte("ddddd"), Attributes: map[string]string{"Cursor": cursor, "googkey": "a"}},
+	})
+	publisher.Publish(ctx, nil)
+	publisher.Publish(ctx, []*pubsub.Message{{Attributes: map[string]string{"Cursor": cursor, "Step": "Undo", "LastValidBlock": "4"}}})
+
+	out := &bytes.Buffer{}
+	require.NoError(t, publisher.WriteSummary(out))
+
+	require.Equal(t, strings.TrimPrefix(`
+Dry run summary
+
+Blocks            2
+Undo signals      1
+Messages          4
+Total size        257 bytes
+Invalid messages  1
+
+Message size (bytes)
+  p50  63
+  p90  65
+  p99  65
+  max  73
+
+Attribute key cardinality (distinct values)
+  Cursor          1
+  LastValidBlock  1
+  Step            1
+  googkey         1
+  kind            2
+
+Largest messages
+  #4  -       73 bytes
+  #4  4-4a-1  65 bytes
+  #4  4-4a-2  63 bytes
+  #4  4-4a-0  56 bytes
+
+Invalid messages (first 1)
+  #4  4-4a-2  attribute key "googkey" must not start with reserved prefix 'goog'
+`, "\n"), out.String())
+}
+
+func TestDryRunBlockEndMarkers(t *testing.T) {
+	ctx := context.Background()
+	cursor := testCursor(4, "4a")
+
+	publisher := NewDryRun()
+	publisher.Publish(ctx, []*pubsub.Message{
+		{ID: "4-4a-0", Data: []byte("d"), Attributes: map[string]string{"Cursor": cursor}},
+		{ID: "4-4a-1", Data: []byte("d"), Attributes: map[string]string{"Cursor": cursor}},
+	})
+	results := publisher.Publish(ctx, []*pubsub.Message{{ID: "4-4a-end", Attributes: map[string]string{"Cursor": cursor, "Step": "BlockEnd", "MessageCount": "2"}}})
+	require.Len(t, results, 1)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, publisher.WriteSummary(out))
+	require.Regexp(t, `Blocks\s+1\n`, out.String())
+	require.Regexp(t, `Block end markers\s+1\n`, out.String())
+	require.Regexp(t, `Messages\s+2\n`, out.String())
+}
+
+func TestDryRunHeartbeats(t *testing.T) {
+	ctx := context.Background()
+
+	publisher := NewDryRun()
+	publisher.Publish(ctx, []*pubsub.Message{{ID: "4-4a-0", Data: []byte("d"), Attributes: map[string]string{"Cursor": testCursor(4, "4a")}}})
+	publisher.Publish(ctx, nil)
+	publisher.Publish(ctx, []*pubsub.Message{{Attributes: map[string]string{"Cursor": testCursor(5, "5a"), "Step": "Heartbeat", "BlockNumber": "5"}}})
+	publisher.Publish(ctx, nil)
+	publisher.Publish(ctx, []*pubsub.Message{{Attributes: map[string]string{"Cursor": testCursor(6, "6a"), "Step": "Heartbeat", "BlockNumber": "6"}}})
+
+	out := &bytes.Buffer{}
+	require.NoError(t, publisher.WriteSummary(out))
+	require.Regexp(t, `Blocks\s+3\n`, out.String())
+	require.Regexp(t, `Heartbeats\s+2\n`, out.String())
+	require.Regexp(t, `Messages\s+1\n`, out.String())
+}
@@ -0,0 +1,388 @@
+package publisher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// FileRecord is the JSON representation of a message written by [File], one per line.
+// Data is base64 encoded. Block number and cursor are decoded from the message's 'Cursor'
+// attribute, for undo messages they are the ones of the last valid block.
+type FileRecord struct {
+	ID          string            `json:"id,omitempty"`
+	BlockNumber uint64            `json:"block_number"`
+	Cursor      string            `json:"cursor,omitempty"`
+	OrderingKey string            `json:"ordering_key,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Data        []byte            `json:"data"`
+}
+
+// FileConfig configures the [File] publisher.
+type FileConfig struct {
+	// Directory receives the files, named '<Name>.jsonl' or, when rotating,
+	// '<Name>-<first block>-<last block>.jsonl' with block numbers zero padded to 10 digits.
+	Directory string
+
+	// Name is the files' base name, usually the topic name.
+	Name string
+
+	// RotateEvery, when greater than 0, writes the messages of each range of RotateEvery
+	// blocks, aligned on multiples of RotateEvery, to their own file.
+	RotateEvery uint64
+
+	// Gzip compresses the files, adding the '.gz' extension.
+	Gzip bool
+}
+
+// File writes messages as JSON lines (see [FileRecord]) to local files or to a stream like
+// stdout, mostly for debugging and archival. Messages are written and flushed when published.
+//
+// When rotating, a file is closed as soon as a message for a later range is published,
+// messages for an earlier block, like undo messages, are written to the current file.
+// Existing files are appended to, restarting the sink from its cursor thus duplicates the
+// messages after the last saved cursor, like other destinations. Compressed files get a new
+// gzip member each time they are opened. A member left without its trailer by a crash is
+// replaced on open by the lines flushed to it, so that the file stays readable.
+type File struct {
+	config FileConfig
+
+	writer      io.Writer
+	closeWriter func() error
+	flushWriter func() error
+
+	rangeStart uint64
+}
+
+// NewFile creates a [File] publisher writing to files as described by config.
+func NewFile(config FileConfig) (*File, error) {
+	if err := os.MkdirAll(config.Directory, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("making directory %q: %w", config.Directory, err)
+	}
+
+	return &File{config: config}, nil
+}
+
+// NewWriterFile creates a [File] publisher writing to writer, rotation and compression are
+// not supported.
+func NewWriterFile(writer io.Writer) *File {
+	buffered := bufio.NewWriter(writer)
+
+	return &File{
+		writer:      buffered,
+		flushWriter: buffered.Flush,
+		closeWriter: buffered.Flush,
+	}
+}
+
+func (p *File) Publish(_ context.Context, messages []*pubsub.Message) []Result {
+	var err error
+
+	for _, message := range messages {
+		record := &FileRecord{
+			ID:          message.ID,
+			Cursor:      message.Attributes["Cursor"],
+			OrderingKey: message.OrderingKey,
+			Attributes:  message.Attributes,
+			Data:        message.Data,
+		}
+		if cursor := messageCursor(message); cursor != nil {
+			record.BlockNumber = cursor.Block().Num()
+		}
+
+		if err = p.write(record); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = p.Flush(context.Background())
+	}
+
+	results := make([]Result, 0, len(messages))
+	for _, message := range messages {
+		results = append(results, resolvedResult(message.ID, err))
+	}
+
+	return results
+}
+
+func (p *File) write(record *FileRecord) error {
+	if p.config.Directory != "" {
+		if err := p.rotate(record.BlockNumber); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+
+	if _, err := p.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing record: %w", err)
+	}
+
+	return nil
+}
+
+// rotate opens the file receiving blockNum's messages if it's not already the current one.
+func (p *File) rotate(blockNum uint64) error {
+	rangeStart := uint64(0)
+	if p.config.RotateEvery > 0 {
+		rangeStart = blockNum - blockNum%p.config.RotateEvery
+	}
+
+	if p.writer != nil && rangeStart <= p.rangeStart {
+		return nil
+	}
+
+	if p.writer != nil {
+		if err := p.closeWriter(); err != nil {
+			return err
+		}
+	}
+
+	name := p.config.Name
+	if p.config.RotateEvery > 0 {
+		name = fmt.Sprintf("%s-%010d-%010d", name, rangeStart, rangeStart+p.config.RotateEvery-1)
+	}
+	name += ".jsonl"
+	if p.config.Gzip {
+		name += ".gz"
+	}
+
+	file, err := os.OpenFile(filepath.Join(p.config.Directory, name), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+
+	buffered := bufio.NewWriter(file)
+	p.rangeStart = rangeStart
+
+	if p.config.Gzip {
+		recovered, err := repairGzip(file)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("repairing %q: %w", name, err)
+		}
+
+		compressed := gzip.NewWriter(buffered)
+		if _, err := compressed.Write(recovered); err != nil {
+			file.Close()
+			return fmt.Errorf("writing recovered records: %w", err)
+		}
+
+		p.writer = compressed
+		p.flushWriter = func() error {
+			if err := compressed.Flush(); err != nil {
+				return err
+			}
+			return buffered.Flush()
+		}
+		p.closeWriter = func() error {
+			if err := compressed.Close(); err != nil {
+				return err
+			}
+			if err := buffered.Flush(); err != nil {
+				return err
+			}
+			return file.Close()
+		}
+	} else {
+		p.writer = buffered
+		p.flushWriter = buffered.Flush
+		p.closeWriter = func() error {
+			if err := buffered.Flush(); err != nil {
+				return err
+			}
+			return file.Close()
+		}
+	}
+
+	return nil
+}
+
+// repairGzip truncates file after its last complete gzip member. The complete lines of an
+// incomplete last member, up to its last flush, are returned to be written again.
+func repairGzip(file *os.File) ([]byte, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reader := &countingReader{reader: bufio.NewReader(file)}
+	var complete int64
+	var recovered []byte
+	for {
+		member, err := gzip.NewReader(reader)
+		if err == io.EOF {
+			return nil, nil
+		}
+
+		if err == nil {
+			member.Multistream(false)
+
+			var data []byte
+			data, err = io.ReadAll(member)
+			if err == nil {
+				complete = reader.read
+				continue
+			}
+
+			recovered = data[:bytes.LastIndexByte(data, '\n')+1]
+		}
+
+		break
+	}
+
+	if err := file.Truncate(complete); err != nil {
+		return nil, err
+	}
+
+	return recovered, nil
+}
+
+// countingReader counts the bytes read, it's a [compress/flate.Reader] so that gzip readers
+// don't read past the end of their member.
+type countingReader struct {
+	reader *bufio.Reader
+	read   int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.read++
+	}
+	return b, err
+}
+
+func (p *File) Flush(_ context.Context) error {
+	if p.flushWriter == nil {
+		return nil
+	}
+
+	if err := p.flushWriter(); err != nil {
+		return fmt.Errorf("flushing: %w", err)
+	}
+
+	return nil
+}
+
+func (p *File) Close() error {
+	if p.closeWriter == nil {
+		return nil
+	}
+
+	return p.closeWriter()
+}
+
+// Message returns the message the record was written from.
+func (r *FileRecord) Message() *pubsub.Message {
+	attributes := make(map[string]string, len(r.Attributes)+1)
+	for key, value := range r.Attributes {
+		attributes[key] = value
+	}
+	if r.Cursor != "" {
+		attributes["Cursor"] = r.Cursor
+	}
+
+	return &pubsub.Message{
+		ID:          r.ID,
+		Data:        r.Data,
+		Attributes:  attributes,
+		OrderingKey: r.OrderingKey,
+	}
+}
+
+// ReadFiles reads the records written by [File] to path, either a single file or a directory
+// whose '.jsonl' and '.jsonl.gz' files are read in name order, which is block order for
+// rotated files. fn is called for each record in the order they were written.
+func ReadFiles(path string, fn func(record *FileRecord) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("reading %q: %w", path, err)
+	}
+
+	paths := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("listing directory %q: %w", path, err)
+		}
+
+		paths = nil
+		for _, entry := range entries {
+			if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".jsonl") || strings.HasSuffix(entry.Name(), ".jsonl.gz")) {
+				paths = append(paths, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(paths)
+	}
+
+	for _, path := range paths {
+		if err := readFile(path, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readFile(path string, fn func(record *FileRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		compressed, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("reading %q: %w", path, err)
+		}
+		defer compressed.Close()
+
+		reader = compressed
+	}
+
+	// Lines hold messages of up to 10MB, base64 encoded, too long for a bufio.Scanner's default
+	buffered := bufio.NewReader(reader)
+	for lineNum := 1; ; lineNum++ {
+		line, err := buffered.ReadBytes('\n')
+		if len(line) > 0 {
+			record := &FileRecord{}
+			if err := json.Unmarshal(line, record); err != nil {
+				return fmt.Errorf("decoding %q line %d: %w", path, lineNum, err)
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %q: %w", path, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package publisher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePublish(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	publisher, err := NewFile(FileConfig{Directory: dir, Name: "topic", RotateEvery: 10, Gzip: true})
+	require.NoError(t, err)
+
+	publish := func(messages ...*pubsub.Message) {
+		for _, result := range publisher.Publish(ctx, messages) {
+			_, err := result.Get(ctx)
+			require.NoError(t, err)
+		}
+	}
+
+	publish(&pubsub.Message{ID: "8-8a-0", Data: []byte("data.1"), Attributes: map[string]string{"Cursor": testCursor(8, "8a")}})
+	publish(&pubsub.Message{ID: "12-12a-0", Data: []byte("data.2"), Attributes: map[string]string{"Cursor": testCursor(12, "12a")}})
+	publish(&pubsub.Message{Attributes: map[string]string{"Cursor": testCursor(9, "9a"), "Step": "Undo"}})
+	require.NoError(t, publisher.Close())
+
+	records := readFileRecords(t, filepath.Join(dir, "topic-0000000000-0000000009.jsonl.gz"))
+	require.Len(t, records, 1)
+	require.Equal(t, &FileRecord{
+		ID:          "8-8a-0",
+		BlockNumber: 8,
+		Cursor:      testCursor(8, "8a"),
+		Attributes:  map[string]string{"Cursor": testCursor(8, "8a")},
+		Data:        []byte("data.1"),
+	}, records[0])
+
+	records = readFileRecords(t, filepath.Join(dir, "topic-0000000010-0000000019.jsonl.gz"))
+	require.Len(t, records, 2)
+	require.Equal(t, uint64(12), records[0].BlockNumber)
+	require.Equal(t, uint64(9), records[1].BlockNumber)
+	require.Equal(t, "Undo", records[1].Attributes["Step"])
+}
+
+func TestWriterFilePublish(t *testing.T) {
+	ctx := context.Background()
+	out := &bytes.Buffer{}
+
+	publisher := NewWriterFile(out)
+	publisher.Publish(ctx, []*pubsub.Message{{Data: []byte("data.1"), OrderingKey: "key"}})
+
+	require.JSONEq(t, `{"block_number":0,"ordering_key":"key","data":"ZGF0YS4x"}`, out.String())
+}
+
+func TestReadFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	publisher, err := NewFile(FileConfig{Directory: dir, Name: "topic", RotateEvery: 10, Gzip: true})
+	require.NoError(t, err)
+
+	for _, message := range []*pubsub.Message{
+		{ID: "8-8a-0", Data: []byte("data.1"), Attributes: map[string]string{"Cursor": testCursor(8, "8a"), "key": "value"}},
+		{ID: "12-12a-0", Data: []byte("data.2"), Attributes: map[string]string{"Cursor": testCursor(12, "12a")}, OrderingKey: "key"},
+		{ID: "25-25a-0", Data: []byte("data.3"), Attributes: map[string]string{"Cursor": testCursor(25, "25a")}},
+	} {
+		_, err := publisher.Publish(ctx, []*pubsub.Message{message})[0].Get(ctx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, publisher.Close())
+
+	var messages []*pubsub.Message
+	require.NoError(t, ReadFiles(dir, func(record *FileRecord) error {
+		messages = append(messages, record.Message())
+		return nil
+	}))
+
+	require.Len(t, messages, 3)
+	require.Equal(t, &pubsub.Message{
+		ID:         "8-8a-0",
+		Data:       []byte("data.1"),
+		Attributes: map[string]string{"Cursor": testCursor(8, "8a"), "key": "value"},
+	}, messages[0])
+	require.Equal(t, "12-12a-0", messages[1].ID)
+	require.Equal(t, "key", messages[1].OrderingKey)
+	require.Equal(t, "25-25a-0", messages[2].ID)
+}
+
+func TestFileReopenGzip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	publish := func(publisher *File, message *pubsub.Message) {
+		_, err := publisher.Publish(ctx, []*pubsub.Message{message})[0].Get(ctx)
+		require.NoError(t, err)
+	}
+
+	// Not closed, like after a crash, the file ends with a member without trailer
+	crashed, err := NewFile(FileConfig{Directory: dir, Name: "topic", Gzip: true})
+	require.NoError(t, err)
+	publish(crashed, &pubsub.Message{ID: "8-8a-0", Attributes: map[string]string{"Cursor": testCursor(8, "8a")}})
+	publish(crashed, &pubsub.Message{ID: "9-9a-0", Attributes: map[string]string{"Cursor": testCursor(9, "9a")}})
+
+	for _, id := range []string{"10-10a-0", "11-11a-0"} {
+		publisher, err := NewFile(FileConfig{Directory: dir, Name: "topic", Gzip: true})
+		require.NoError(t, err)
+		publish(publisher, &pubsub.Message{ID: id, Attributes: map[string]string{"Cursor": testCursor(10, "10a")}})
+		require.NoError(t, publisher.Close())
+	}
+
+	var ids []string
+	require.NoError(t, ReadFiles(dir, func(record *FileRecord) error {
+		ids = append(ids, record.ID)
+		return nil
+	}))
+	require.Equal(t, []string{"8-8a-0", "9-9a-0", "10-10a-0", "11-11a-0"}, ids)
+}
+
+func readFileRecords(t *testing.T, path string) (records []*FileRecord) {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		record := &FileRecord{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+
+	return records
+}
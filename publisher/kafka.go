@@ -0,0 +1,225 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Kafka publishes messages to a Kafka topic. The message's ordering key becomes the record's
+// key, so that messages sharing an ordering key land on the same partition and keep their
+// relative order, and the message's attributes become the record's headers.
+//
+// Undo messages ('Step=Undo' attribute) are produced to every partition of the topic, so that
+// the consumers of each partition receive them after the messages they undo, whichever
+// partition those landed on.
+//
+// The producer is idempotent and waits for all in-sync replicas to acknowledge a record
+// before resolving its [Result], retried sends can thus never duplicate or reorder records
+// within a partition.
+type Kafka struct {
+	client *kgo.Client
+	topic  string
+}
+
+// NewKafka creates a Kafka publisher producing to topic through the given seed brokers, extra
+// options are appended to the default ones and can be used for example to configure TLS or SASL.
+func NewKafka(brokers []string, topic string, opts ...kgo.Opt) (*Kafka, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("at least one seed broker is required")
+	}
+
+	clientOpts := append([]kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.RecordPartitioner(kafkaPartitioner{kgo.StickyKeyPartitioner(nil)}),
+	}, opts...)
+
+	client, err := kgo.NewClient(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating kafka client: %w", err)
+	}
+
+	return &Kafka{
+		client: client,
+		topic:  topic,
+	}, nil
+}
+
+func (p *Kafka) Publish(ctx context.Context, messages []*pubsub.Message) []Result {
+	results := make([]Result, 0, len(messages))
+	for _, message := range messages {
+		result := newAsyncResult()
+		results = append(results, result)
+
+		if message.Attributes["Step"] == "Undo" {
+			p.produceToAllPartitions(ctx, message, result)
+			continue
+		}
+
+		p.client.Produce(ctx, kafkaRecord(p.topic, message), func(record *kgo.Record, err error) {
+			if err != nil {
+				result.set("", err)
+				return
+			}
+
+			result.set(fmt.Sprintf("%d/%d", record.Partition, record.Offset), nil)
+		})
+	}
+
+	return results
+}
+
+// produceToAllPartitions produces a record of message to each partition of the topic,
+// resolving result once they are all acknowledged, with their '<partition>/<offset>' joined.
+func (p *Kafka) produceToAllPartitions(ctx context.Context, message *pubsub.Message, result *asyncResult) {
+	partitions, err := p.partitions(ctx)
+	if err != nil {
+		result.set("", err)
+		return
+	}
+
+	var lock sync.Mutex
+	ids := make([]string, partitions)
+	var firstErr error
+	remaining := partitions
+
+	for partition := 0; partition < partitions; partition++ {
+		record := kafkaRecord(p.topic, message)
+		record.Partition = int32(partition)
+		record.Context = context.WithValue(ctx, kafkaAllPartitionsKey{}, true)
+
+		p.client.Produce(ctx, record, func(record *kgo.Record, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("producing to partition %d: %w", record.Partition, err)
+			}
+			ids[record.Partition] = fmt.Sprintf("%d/%d", record.Partition, record.Offset)
+
+			remaining--
+			if remaining == 0 {
+				if firstErr != nil {
+					result.set("", firstErr)
+				} else {
+					result.set(strings.Join(ids, ","), nil)
+				}
+			}
+		})
+	}
+}
+
+// partitions returns the number of partitions of the topic.
+func (p *Kafka) partitions(ctx context.Context) (int, error) {
+	topic := kmsg.NewMetadataRequestTopic()
+	topic.Topic = kmsg.StringPtr(p.topic)
+
+	request := kmsg.NewPtrMetadataRequest()
+	request.Topics = append(request.Topics, topic)
+
+	response, err := request.RequestWith(ctx, p.client)
+	if err != nil {
+		return 0, fmt.Errorf("fetching topic %q metadata: %w", p.topic, err)
+	}
+
+	if len(response.Topics) != 1 {
+		return 0, fmt.Errorf("topic %q not found in metadata", p.topic)
+	}
+	if err := kerr.ErrorForCode(response.Topics[0].ErrorCode); err != nil {
+		return 0, fmt.Errorf("fetching topic %q metadata: %w", p.topic, err)
+	}
+	if len(response.Topics[0].Partitions) == 0 {
+		return 0, fmt.Errorf("topic %q has no partition", p.topic)
+	}
+
+	return len(response.Topics[0].Partitions), nil
+}
+
+// kafkaAllPartitionsKey marks, in their context, the records produced to each partition by
+// [Kafka.produceToAllPartitions].
+type kafkaAllPartitionsKey struct{}
+
+// kafkaPartitioner keeps the partition of the records produced to each partition and
+// delegates the others to the wrapped partitioner.
+type kafkaPartitioner struct {
+	kgo.Partitioner
+}
+
+func (p kafkaPartitioner) ForTopic(topic string) kgo.TopicPartitioner {
+	return &kafkaTopicPartitioner{TopicPartitioner: p.Partitioner.ForTopic(topic)}
+}
+
+type kafkaTopicPartitioner struct {
+	kgo.TopicPartitioner
+}
+
+func (p *kafkaTopicPartitioner) RequiresConsistency(record *kgo.Record) bool {
+	return allPartitionsRecord(record) || p.TopicPartitioner.RequiresConsistency(record)
+}
+
+func (p *kafkaTopicPartitioner) Partition(record *kgo.Record, n int) int {
+	if allPartitionsRecord(record) {
+		return int(record.Partition)
+	}
+
+	return p.TopicPartitioner.Partition(record, n)
+}
+
+func (p *kafkaTopicPartitioner) OnNewBatch() {
+	if onNewBatch, ok := p.TopicPartitioner.(kgo.TopicPartitionerOnNewBatch); ok {
+		onNewBatch.OnNewBatch()
+	}
+}
+
+func allPartitionsRecord(record *kgo.Record) bool {
+	return record.Context != nil && record.Context.Value(kafkaAllPartitionsKey{}) != nil
+}
+
+func (p *Kafka) Flush(ctx context.Context) error {
+	return p.client.Flush(ctx)
+}
+
+func (p *Kafka) Close() error {
+	err := p.client.Flush(context.Background())
+	p.client.Close()
+
+	return err
+}
+
+func kafkaRecord(topic string, message *pubsub.Message) *kgo.Record {
+	record := &kgo.Record{
+		Topic: topic,
+		Value: message.Data,
+	}
+
+	if message.OrderingKey != "" {
+		record.Key = []byte(message.OrderingKey)
+	}
+
+	for _, key := range sortedKeys(message.Attributes) {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: key, Value: []byte(message.Attributes[key])})
+	}
+
+	return record
+}
+
+// sortedKeys returns the attribute keys in lexicographic order so that backends mapping
+// attributes to ordered headers produce deterministic output.
+func sortedKeys(attributes map[string]string) []string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
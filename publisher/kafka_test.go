@@ -0,0 +1,121 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestKafkaPublish(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, "topic"))
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	publisher, err := NewKafka(cluster.ListenAddrs(), "topic")
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	messages := []*pubsub.Message{
+		{
+			Data:        []byte("data.1"),
+			OrderingKey: "000000004_00000",
+			Attributes:  map[string]string{"Cursor": "c1", "key1": "value1"},
+		},
+		{
+			Data:        []byte("data.2"),
+			OrderingKey: "000000004_00001",
+			Attributes:  map[string]string{"Cursor": "c1"},
+		},
+		{
+			Data:       nil,
+			Attributes: map[string]string{"LastValidBlock": "4", "Step": "Undo", "Cursor": "c2"},
+		},
+	}
+
+	results := publisher.Publish(ctx, messages)
+	require.Len(t, results, len(messages))
+	require.NoError(t, publisher.Flush(ctx))
+
+	for _, result := range results {
+		id, err := result.Get(ctx)
+		require.NoError(t, err)
+		require.NotEmpty(t, id)
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumeTopics("topic"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	// The undo message is produced to each of the 3 partitions
+	received := map[string]*kgo.Record{}
+	undoPartitions := map[int32]bool{}
+	for len(received) < len(messages) || len(undoPartitions) < 3 {
+		fetches := consumer.PollFetches(ctx)
+		require.NoError(t, fetches.Err())
+
+		fetches.EachRecord(func(record *kgo.Record) {
+			received[headerValue(record, "Cursor")+"/"+string(record.Value)] = record
+			if headerValue(record, "Step") == "Undo" {
+				undoPartitions[record.Partition] = true
+			}
+		})
+	}
+	require.Len(t, undoPartitions, 3)
+
+	undoID, err := results[2].Get(ctx)
+	require.NoError(t, err)
+	require.Regexp(t, `^0/\d+,1/\d+,2/\d+$`, undoID)
+
+	record := received["c2/"]
+	require.Nil(t, record.Key)
+	require.Empty(t, record.Value)
+	require.Equal(t, []kgo.RecordHeader{
+		{Key: "Cursor", Value: []byte("c2")},
+		{Key: "LastValidBlock", Value: []byte("4")},
+		{Key: "Step", Value: []byte("Undo")},
+	}, record.Headers)
+
+	record = received["c1/data.2"]
+	require.Equal(t, "000000004_00001", string(record.Key))
+	require.Equal(t, "data.2", string(record.Value))
+}
+
+func TestKafkaRecord(t *testing.T) {
+	record := kafkaRecord("topic", &pubsub.Message{
+		Data:        []byte("data.1"),
+		OrderingKey: "000000004_00000",
+		Attributes:  map[string]string{"b": "2", "a": "1"},
+	})
+
+	require.Equal(t, &kgo.Record{
+		Topic: "topic",
+		Key:   []byte("000000004_00000"),
+		Value: []byte("data.1"),
+		Headers: []kgo.RecordHeader{
+			{Key: "a", Value: []byte("1")},
+			{Key: "b", Value: []byte("2")},
+		},
+	}, record)
+}
+
+func headerValue(record *kgo.Record, key string) string {
+	for _, header := range record.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,64 @@
+package publisher
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Google Cloud PubSub limits, see https://cloud.google.com/pubsub/quotas#resource_limits
+const (
+	PubSubMaxMessageSize        = 10 * 1000 * 1000
+	PubSubMaxAttributes         = 100
+	PubSubMaxAttributeKeySize   = 256
+	PubSubMaxAttributeValueSize = 1024
+	PubSubMaxOrderingKeySize    = 1024
+)
+
+// MessageSize returns the size PubSub accounts for the message, its data, ordering key and
+// attributes' keys and values.
+func MessageSize(message *pubsub.Message) int {
+	size := len(message.Data) + len(message.OrderingKey)
+	for key, value := range message.Attributes {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
+
+// ValidatePubSubMessage returns an error if the message would be rejected by PubSub.
+func ValidatePubSubMessage(message *pubsub.Message) error {
+	if size := MessageSize(message); size > PubSubMaxMessageSize {
+		return fmt.Errorf("message size %d bytes exceeds the %d bytes limit", size, PubSubMaxMessageSize)
+	}
+
+	if len(message.Data) == 0 && len(message.Attributes) == 0 {
+		return fmt.Errorf("message must have data or at least one attribute")
+	}
+
+	if len(message.Attributes) > PubSubMaxAttributes {
+		return fmt.Errorf("message has %d attributes, exceeding the %d attributes limit", len(message.Attributes), PubSubMaxAttributes)
+	}
+
+	for key, value := range message.Attributes {
+		if key == "" {
+			return fmt.Errorf("attribute key must not be empty")
+		}
+		if strings.HasPrefix(key, "goog") {
+			return fmt.Errorf("attribute key %q must not start with reserved prefix 'goog'", key)
+		}
+		if len(key) > PubSubMaxAttributeKeySize {
+			return fmt.Errorf("attribute key %q exceeds the %d bytes limit", key, PubSubMaxAttributeKeySize)
+		}
+		if len(value) > PubSubMaxAttributeValueSize {
+			return fmt.Errorf("attribute %q value of %d bytes exceeds the %d bytes limit", key, len(value), PubSubMaxAttributeValueSize)
+		}
+	}
+
+	if len(message.OrderingKey) > PubSubMaxOrderingKeySize {
+		return fmt.Errorf("ordering key of %d bytes exceeds the %d bytes limit", len(message.OrderingKey), PubSubMaxOrderingKeySize)
+	}
+
+	return nil
+}
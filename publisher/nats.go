@@ -0,0 +1,128 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATS publishes messages to a NATS JetStream stream. The subject is rendered from a
+// [Template] for each message, the message's attributes become headers and the message's
+// ID, the deterministic identity assigned by the sink, is sent as the 'Nats-Msg-Id' header
+// so that JetStream discards messages re-published after a restart within the stream's
+// duplicate window.
+//
+// The stream capturing the subjects must already exist.
+type NATS struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject *Template
+
+	closeTimeout time.Duration
+}
+
+// NATSCloseTimeout is the time [NATS.Close] waits for the acknowledgement of the messages in
+// flight before closing the connection.
+const NATSCloseTimeout = 30 * time.Second
+
+// NewNATS connects to the NATS server(s) at url (comma separated for multiple servers)
+// and publishes to the subject rendered from subjectTemplate.
+func NewNATS(url string, subjectTemplate string, opts ...nats.Option) (*NATS, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("creating jetstream context: %w", err)
+	}
+
+	return &NATS{
+		conn:         conn,
+		js:           js,
+		subject:      NewTemplate(subjectTemplate),
+		closeTimeout: NATSCloseTimeout,
+	}, nil
+}
+
+func (p *NATS) Publish(ctx context.Context, messages []*pubsub.Message) []Result {
+	results := make([]Result, 0, len(messages))
+	for _, message := range messages {
+		msg, err := p.natsMsg(message)
+		if err != nil {
+			results = append(results, resolvedResult("", err))
+			continue
+		}
+
+		var opts []jetstream.PublishOpt
+		if message.ID != "" {
+			opts = append(opts, jetstream.WithMsgID(message.ID))
+		}
+
+		future, err := p.js.PublishMsgAsync(msg, opts...)
+		if err != nil {
+			results = append(results, resolvedResult("", fmt.Errorf("publishing to %q: %w", msg.Subject, err)))
+			continue
+		}
+
+		result := newAsyncResult()
+		results = append(results, result)
+
+		go func() {
+			select {
+			case ack := <-future.Ok():
+				result.set(fmt.Sprintf("%s/%d", ack.Stream, ack.Sequence), nil)
+			case err := <-future.Err():
+				result.set("", fmt.Errorf("publishing to %q: %w", msg.Subject, err))
+			}
+		}()
+	}
+
+	return results
+}
+
+func (p *NATS) Flush(ctx context.Context) error {
+	select {
+	case <-p.js.PublishAsyncComplete():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close waits up to [NATSCloseTimeout] for the messages in flight to be acknowledged, then
+// drains the connection. When the wait times out, the connection is closed and the messages
+// still in flight are lost.
+func (p *NATS) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.closeTimeout)
+	defer cancel()
+
+	if err := p.Flush(ctx); err != nil {
+		pending := p.js.PublishAsyncPending()
+		p.conn.Close()
+		return fmt.Errorf("waiting for %d messages in flight: %w", pending, err)
+	}
+
+	return p.conn.Drain()
+}
+
+func (p *NATS) natsMsg(message *pubsub.Message) (*nats.Msg, error) {
+	subject, err := p.subject.Render(message)
+	if err != nil {
+		return nil, fmt.Errorf("rendering subject: %w", err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = message.Data
+	for key, value := range message.Attributes {
+		msg.Header.Set(key, value)
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,131 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/streamingfast/bstream"
+	sink "github.com/streamingfast/substreams-sink"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSPublish(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natstest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	publisher, err := NewNATS(srv.ClientURL(), "chain.{{BlockNumber}}")
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	stream, err := publisher.js.CreateStream(ctx, jetstream.StreamConfig{Name: "chain", Subjects: []string{"chain.>"}})
+	require.NoError(t, err)
+
+	cursor := testCursor(3, "3a")
+	messages := []*pubsub.Message{
+		{
+			ID:         "3-3a-0",
+			Data:       []byte("data.1"),
+			Attributes: map[string]string{"Cursor": cursor, "key1": "value1"},
+		},
+		{
+			ID:         "3-3a-1",
+			Data:       []byte("data.2"),
+			Attributes: map[string]string{"Cursor": cursor},
+		},
+		{
+			// Re-publication of an already published message, e.g. after a restart
+			ID:         "3-3a-0",
+			Data:       []byte("data.1"),
+			Attributes: map[string]string{"Cursor": cursor, "key1": "value1"},
+		},
+	}
+
+	results := publisher.Publish(ctx, messages)
+	require.Len(t, results, len(messages))
+	require.NoError(t, publisher.Flush(ctx))
+
+	for _, result := range results {
+		_, err := result.Get(ctx)
+		require.NoError(t, err)
+	}
+
+	info, err := stream.Info(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), info.State.Msgs)
+
+	msg, err := stream.GetMsg(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, "chain.3", msg.Subject)
+	require.Equal(t, "data.1", string(msg.Data))
+	require.Equal(t, nats.Header{
+		"Cursor":              []string{cursor},
+		"key1":                []string{"value1"},
+		jetstream.MsgIDHeader: []string{"3-3a-0"},
+	}, msg.Header)
+}
+
+func TestNATSPublishInvalidSubject(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natstest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	publisher, err := NewNATS(srv.ClientURL(), "chain.{{Missing}}")
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	results := publisher.Publish(context.Background(), []*pubsub.Message{{Data: []byte("data.1")}})
+	_, err = results[0].Get(context.Background())
+	require.EqualError(t, err, `rendering subject: template "chain.{{Missing}}": message has no attribute "Missing"`)
+}
+
+func testCursor(blockNum uint64, blockID string) string {
+	cursor := &sink.Cursor{
+		Cursor: &bstream.Cursor{
+			Step:      bstream.StepNew,
+			Block:     bstream.NewBlockRef(blockID, blockNum),
+			LIB:       bstream.NewBlockRef("lib", blockNum-1),
+			HeadBlock: bstream.NewBlockRef(blockID, blockNum),
+		},
+	}
+
+	return cursor.String()
+}
+
+func TestNATSCloseTimeout(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natstest.RunServer(&opts)
+
+	publisher, err := NewNATS(srv.ClientURL(), "chain.{{BlockNumber}}")
+	require.NoError(t, err)
+	publisher.closeTimeout = 100 * time.Millisecond
+
+	// The server is gone, the message is buffered until a reconnection that never happens
+	srv.Shutdown()
+	publisher.Publish(context.Background(), []*pubsub.Message{
+		{ID: "3-3a-0", Data: []byte("data.1"), Attributes: map[string]string{"Cursor": testCursor(3, "3a")}},
+	})
+
+	start := time.Now()
+	err = publisher.Close()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "waiting for 1 messages in flight")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
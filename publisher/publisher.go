@@ -0,0 +1,99 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	sink "github.com/streamingfast/substreams-sink"
+)
+
+// Publisher is a destination the sink sends its messages to. The sink never calls a
+// Publisher concurrently, but implementations are free to send messages in the background
+// and must report the outcome of each message through the returned [Result].
+type Publisher interface {
+	// Publish enqueues the messages for sending and returns one [Result] per message, in the
+	// same order as the received messages.
+	Publish(ctx context.Context, messages []*pubsub.Message) []Result
+
+	// Flush blocks until every message enqueued so far has been sent or has failed.
+	Flush(ctx context.Context) error
+
+	// Close flushes pending messages and releases the resources held by the publisher.
+	Close() error
+}
+
+// Checker is implemented by publishers able to check that their destination is reachable.
+type Checker interface {
+	// Check returns an error if the destination doesn't exist or cannot be reached.
+	Check(ctx context.Context) error
+}
+
+// Result is the outcome of publishing a single message, it has the same semantics as
+// [pubsub.PublishResult] which satisfies the interface.
+type Result interface {
+	// Get blocks until the message has been acknowledged by the destination or has failed,
+	// returning the identifier assigned to the message by the destination.
+	Get(ctx context.Context) (id string, err error)
+}
+
+// asyncResult is a [Result] resolved once by a background acknowledgement callback.
+type asyncResult struct {
+	done chan struct{}
+	id   string
+	err  error
+}
+
+func newAsyncResult() *asyncResult {
+	return &asyncResult{done: make(chan struct{})}
+}
+
+func (r *asyncResult) set(id string, err error) {
+	r.id = id
+	r.err = err
+	close(r.done)
+}
+
+func (r *asyncResult) Get(ctx context.Context) (string, error) {
+	select {
+	case <-r.done:
+		return r.id, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// resolvedResult returns a [Result] that is already resolved with the given values.
+func resolvedResult(id string, err error) Result {
+	r := newAsyncResult()
+	r.set(id, err)
+
+	return r
+}
+
+// waitGroupContext waits for wg to complete or for ctx to be done, whichever comes first.
+func waitGroupContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// messageCursor decodes the message's 'Cursor' attribute set by the sink, returning nil if
+// the message has none or if it is invalid.
+func messageCursor(message *pubsub.Message) *sink.Cursor {
+	cursor, err := sink.NewCursor(message.Attributes["Cursor"])
+	if err != nil || cursor.IsBlank() {
+		return nil
+	}
+
+	return cursor
+}
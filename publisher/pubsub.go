@@ -0,0 +1,176 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrTopicNotFound is returned by the preflight checks when the topic doesn't exist.
+	ErrTopicNotFound = errors.New("topic not found")
+
+	// ErrPublishPermissionDenied is returned by [PubSub.CheckPublishPermission] when the
+	// credentials lack the 'pubsub.topics.publish' permission.
+	ErrPublishPermissionDenied = errors.New("permission 'pubsub.topics.publish' denied")
+
+	// ErrMessageOrderingDisabled is returned by [PubSub.CheckMessageOrdering] when a subscription
+	// of the topic doesn't deliver messages in ordering key order.
+	ErrMessageOrderingDisabled = errors.New("message ordering disabled")
+)
+
+// PubSub publishes messages to a Google Cloud PubSub topic.
+type PubSub struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+	closer io.Closer
+}
+
+func NewPubSub(client *pubsub.Client, topic *pubsub.Topic) *PubSub {
+	return &PubSub{
+		client: client,
+		topic:  topic,
+	}
+}
+
+// SetClientCloser makes [PubSub.Close] close the client with closer, for a client wrapped along
+// with resources it doesn't release itself, like the gRPC connection it was created with.
+func (p *PubSub) SetClientCloser(closer io.Closer) {
+	p.closer = closer
+}
+
+// MessageOrdering returns true if the topic publishes messages sharing an ordering key in
+// order, which is required to publish messages with an ordering key.
+func (p *PubSub) MessageOrdering() bool {
+	return p.topic.EnableMessageOrdering
+}
+
+func (p *PubSub) Publish(ctx context.Context, messages []*pubsub.Message) []Result {
+	results := make([]Result, 0, len(messages))
+	for _, message := range messages {
+		results = append(results, p.topic.Publish(ctx, message))
+	}
+
+	return results
+}
+
+func (p *PubSub) Flush(_ context.Context) error {
+	p.topic.Flush()
+	return nil
+}
+
+// Check returns an error if the topic doesn't exist, the credentials need the
+// 'pubsub.topics.get' permission on it.
+func (p *PubSub) Check(ctx context.Context) error {
+	exists, err := p.topic.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("checking topic %q: %w", p.topic.ID(), err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrTopicNotFound, p.topic.ID())
+	}
+
+	return nil
+}
+
+// EnsureTopic returns [ErrTopicNotFound] if the topic doesn't exist, or creates it when create
+// is true, returning true if it was created. Existence is not checked when the credentials
+// lack the 'pubsub.topics.get' permission.
+func (p *PubSub) EnsureTopic(ctx context.Context, create bool) (created bool, err error) {
+	exists, err := p.topic.Exists(ctx)
+	if status.Code(err) == codes.PermissionDenied {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("checking topic %q: %w", p.topic.ID(), err)
+	}
+
+	if exists {
+		return false, nil
+	}
+
+	if !create {
+		return false, fmt.Errorf("%w: %q", ErrTopicNotFound, p.topic.ID())
+	}
+
+	if _, err := p.client.CreateTopic(ctx, p.topic.ID()); err != nil && status.Code(err) != codes.AlreadyExists {
+		return false, fmt.Errorf("creating topic %q: %w", p.topic.ID(), err)
+	}
+
+	return true, nil
+}
+
+// CheckPublishPermission returns [ErrPublishPermissionDenied] if the credentials cannot publish
+// to the topic. Endpoints not implementing IAM, like emulators, are not checked.
+func (p *PubSub) CheckPublishPermission(ctx context.Context) error {
+	permissions, err := p.topic.IAM().TestPermissions(ctx, []string{"pubsub.topics.publish"})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.Unimplemented:
+		return nil
+	case codes.NotFound:
+		return fmt.Errorf("%w: %q", ErrTopicNotFound, p.topic.ID())
+	default:
+		return fmt.Errorf("testing permissions on topic %q: %w", p.topic.ID(), err)
+	}
+
+	if len(permissions) == 0 {
+		return fmt.Errorf("%w on topic %q", ErrPublishPermissionDenied, p.topic.ID())
+	}
+
+	return nil
+}
+
+// CheckMessageOrdering returns [ErrMessageOrderingDisabled] if a subscription of the topic
+// doesn't have message ordering enabled, in which case its messages are delivered in any order
+// whatever their ordering key. Subscriptions are not checked when the credentials lack the
+// 'pubsub.topics.listSubscriptions' or 'pubsub.subscriptions.get' permission.
+func (p *PubSub) CheckMessageOrdering(ctx context.Context) error {
+	var unordered []string
+	subscriptions := p.topic.Subscriptions(ctx)
+	for {
+		subscription, err := subscriptions.Next()
+		if err == iterator.Done {
+			break
+		}
+		if status.Code(err) == codes.PermissionDenied {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("listing subscriptions of topic %q: %w", p.topic.ID(), err)
+		}
+
+		config, err := subscription.Config(ctx)
+		if status.Code(err) == codes.PermissionDenied {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("getting subscription %q: %w", subscription.ID(), err)
+		}
+
+		if !config.EnableMessageOrdering {
+			unordered = append(unordered, subscription.ID())
+		}
+	}
+
+	if len(unordered) > 0 {
+		return fmt.Errorf("%w on subscriptions %q of topic %q", ErrMessageOrderingDisabled, unordered, p.topic.ID())
+	}
+
+	return nil
+}
+
+func (p *PubSub) Close() error {
+	p.topic.Stop()
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+
+	return p.client.Close()
+}
@@ -0,0 +1,89 @@
+package publisher
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestPubSubPreflight(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client, err := pubsub.NewClient(ctx, "project", option.WithGRPCConn(conn))
+	require.NoError(t, err)
+
+	publisher := NewPubSub(client, client.Topic("topic"))
+
+	_, err = publisher.EnsureTopic(ctx, false)
+	require.ErrorIs(t, err, ErrTopicNotFound)
+	require.ErrorIs(t, publisher.Check(ctx), ErrTopicNotFound)
+
+	created, err := publisher.EnsureTopic(ctx, true)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	created, err = publisher.EnsureTopic(ctx, true)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.NoError(t, publisher.Check(ctx))
+
+	// The emulator doesn't implement IAM, the permission is not checked
+	require.NoError(t, publisher.CheckPublishPermission(ctx))
+
+	// Without subscriptions, nothing is delivered out of order
+	require.NoError(t, publisher.CheckMessageOrdering(ctx))
+
+	_, err = client.CreateSubscription(ctx, "ordered", pubsub.SubscriptionConfig{Topic: client.Topic("topic"), EnableMessageOrdering: true})
+	require.NoError(t, err)
+	require.NoError(t, publisher.CheckMessageOrdering(ctx))
+
+	_, err = client.CreateSubscription(ctx, "unordered", pubsub.SubscriptionConfig{Topic: client.Topic("topic")})
+	require.NoError(t, err)
+	err = publisher.CheckMessageOrdering(ctx)
+	require.ErrorIs(t, err, ErrMessageOrderingDisabled)
+	require.ErrorContains(t, err, `"unordered"`)
+	require.NotContains(t, err.Error(), `"ordered"`)
+}
+
+type countingCloser struct {
+	io.Closer
+	calls int
+}
+
+func (c *countingCloser) Close() error {
+	c.calls++
+	return c.Closer.Close()
+}
+
+func TestPubSubClientCloser(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client, err := pubsub.NewClient(ctx, "project", option.WithGRPCConn(conn))
+	require.NoError(t, err)
+
+	closer := &countingCloser{Closer: client}
+	publisher := NewPubSub(client, client.Topic("topic"))
+	publisher.SetClientCloser(closer)
+
+	require.NoError(t, publisher.Close())
+	require.Equal(t, 1, closer.calls)
+}
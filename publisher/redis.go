@@ -0,0 +1,106 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisDataField is the stream entry field holding the message's data, the other fields of
+// the entry are the message's attributes.
+const RedisDataField = "data"
+
+// Redis publishes messages to a Redis stream using 'XADD', each message becoming an entry
+// with its data in the [RedisDataField] field and one field per attribute. Undo messages
+// go to the same stream as the block messages, preserving their relative order.
+//
+// When maxLen is greater than 0, the stream is trimmed to approximately maxLen entries on
+// each addition.
+type Redis struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+
+	inflight sync.WaitGroup
+}
+
+func NewRedis(opts *redis.Options, stream string, maxLen int64) *Redis {
+	return &Redis{
+		client: redis.NewClient(opts),
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+func (p *Redis) Publish(ctx context.Context, messages []*pubsub.Message) []Result {
+	results := make([]Result, len(messages))
+	pipeline := p.client.Pipeline()
+
+	var commands []*redis.StringCmd
+	var commandResults []*asyncResult
+	for i, message := range messages {
+		values, err := redisValues(message)
+		if err != nil {
+			results[i] = resolvedResult("", err)
+			continue
+		}
+
+		commands = append(commands, pipeline.XAdd(ctx, &redis.XAddArgs{
+			Stream: p.stream,
+			MaxLen: p.maxLen,
+			Approx: p.maxLen > 0,
+			Values: values,
+		}))
+
+		result := newAsyncResult()
+		results[i] = result
+		commandResults = append(commandResults, result)
+	}
+
+	if len(commands) == 0 {
+		return results
+	}
+
+	p.inflight.Add(1)
+	go func() {
+		defer p.inflight.Done()
+
+		// Errors are reported per command, so the aggregated one returned here is not needed
+		_, _ = pipeline.Exec(ctx)
+		for i, command := range commands {
+			id, err := command.Result()
+			if err != nil {
+				err = fmt.Errorf("adding to stream %q: %w", p.stream, err)
+			}
+			commandResults[i].set(id, err)
+		}
+	}()
+
+	return results
+}
+
+func (p *Redis) Flush(ctx context.Context) error {
+	return waitGroupContext(ctx, &p.inflight)
+}
+
+func (p *Redis) Close() error {
+	p.inflight.Wait()
+	return p.client.Close()
+}
+
+func redisValues(message *pubsub.Message) ([]interface{}, error) {
+	values := make([]interface{}, 0, 2+2*len(message.Attributes))
+	values = append(values, RedisDataField, message.Data)
+
+	for _, key := range sortedKeys(message.Attributes) {
+		if key == RedisDataField {
+			return nil, fmt.Errorf("attribute %q conflicts with the stream entry's data field", key)
+		}
+		values = append(values, key, message.Attributes[key])
+	}
+
+	return values, nil
+}
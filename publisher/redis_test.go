@@ -0,0 +1,69 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisPublish(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+
+	publisher := NewRedis(&redis.Options{Addr: srv.Addr()}, "topic", 0)
+	defer publisher.Close()
+
+	messages := []*pubsub.Message{
+		{
+			Data:       []byte("data.1"),
+			Attributes: map[string]string{"Cursor": "c1", "key1": "value1"},
+		},
+		{
+			Data:       nil,
+			Attributes: map[string]string{"LastValidBlock": "4", "Step": "Undo", "Cursor": "c2"},
+		},
+		{
+			Data:       []byte("data.2"),
+			Attributes: map[string]string{"data": "conflicting"},
+		},
+	}
+
+	results := publisher.Publish(ctx, messages)
+	require.NoError(t, publisher.Flush(ctx))
+
+	_, err := results[0].Get(ctx)
+	require.NoError(t, err)
+	_, err = results[1].Get(ctx)
+	require.NoError(t, err)
+	_, err = results[2].Get(ctx)
+	require.EqualError(t, err, `attribute "data" conflicts with the stream entry's data field`)
+
+	entries, err := publisher.client.XRange(ctx, "topic", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, map[string]interface{}{"data": "data.1", "Cursor": "c1", "key1": "value1"}, entries[0].Values)
+	require.Equal(t, map[string]interface{}{"data": "", "LastValidBlock": "4", "Step": "Undo", "Cursor": "c2"}, entries[1].Values)
+}
+
+func TestRedisPublishMaxLen(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+
+	publisher := NewRedis(&redis.Options{Addr: srv.Addr()}, "topic", 2)
+	defer publisher.Close()
+
+	for _, data := range []string{"data.1", "data.2", "data.3"} {
+		results := publisher.Publish(ctx, []*pubsub.Message{{Data: []byte(data)}})
+		_, err := results[0].Get(ctx)
+		require.NoError(t, err)
+	}
+
+	entries, err := publisher.client.XRange(ctx, "topic", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "data.3", entries[1].Values["data"])
+}
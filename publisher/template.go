@@ -0,0 +1,89 @@
+package publisher
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	sink "github.com/streamingfast/substreams-sink"
+)
+
+var placeholderRegexp = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// Template is a destination name (subject, routing key, etc.) in which '{{<Name>}}'
+// placeholders are replaced by values taken from each published message. The following
+// names are recognized:
+//
+//   - BlockNumber: the block number, decoded from the message's 'Cursor' attribute (the last
+//     valid block for undo messages)
+//   - BlockID: the block ID, decoded from the message's 'Cursor' attribute
+//   - OrderingKey: the message's ordering key
+//
+// Any other name is looked up in the message's attributes, for example '{{Step}}'.
+type Template struct {
+	raw          string
+	placeholders [][]int
+}
+
+func NewTemplate(raw string) *Template {
+	return &Template{
+		raw:          raw,
+		placeholders: placeholderRegexp.FindAllStringSubmatchIndex(raw, -1),
+	}
+}
+
+// IsStatic returns true if the template has no placeholder and always renders to the same value.
+func (t *Template) IsStatic() bool {
+	return len(t.placeholders) == 0
+}
+
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Render returns the template's value for message, it is an error for a placeholder to
+// reference an attribute absent from message.
+func (t *Template) Render(message *pubsub.Message) (string, error) {
+	if t.IsStatic() {
+		return t.raw, nil
+	}
+
+	var cursor *sink.Cursor
+	out := strings.Builder{}
+	last := 0
+	for _, placeholder := range t.placeholders {
+		out.WriteString(t.raw[last:placeholder[0]])
+		last = placeholder[1]
+
+		name := t.raw[placeholder[2]:placeholder[3]]
+		switch name {
+		case "BlockNumber", "BlockID":
+			if cursor == nil {
+				if cursor = messageCursor(message); cursor == nil {
+					return "", fmt.Errorf("template %q: message has no valid 'Cursor' attribute to resolve %s", t.raw, name)
+				}
+			}
+
+			if name == "BlockNumber" {
+				out.WriteString(strconv.FormatUint(cursor.Block().Num(), 10))
+			} else {
+				out.WriteString(cursor.Block().ID())
+			}
+
+		case "OrderingKey":
+			out.WriteString(message.OrderingKey)
+
+		default:
+			value, found := message.Attributes[name]
+			if !found {
+				return "", fmt.Errorf("template %q: message has no attribute %q", t.raw, name)
+			}
+			out.WriteString(value)
+		}
+	}
+	out.WriteString(t.raw[last:])
+
+	return out.String(), nil
+}
@@ -0,0 +1,41 @@
+package publisher
+
+import (
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateRender(t *testing.T) {
+	message := &pubsub.Message{
+		OrderingKey: "000000004_00000",
+		Attributes:  map[string]string{"Cursor": testCursor(4, "4a"), "Step": "Undo"},
+	}
+
+	cases := []struct {
+		name        string
+		template    string
+		expected    string
+		expectedErr string
+	}{
+		{name: "static", template: "chain", expected: "chain"},
+		{name: "block", template: "chain.{{BlockNumber}}.{{ BlockID }}", expected: "chain.4.4a"},
+		{name: "ordering key", template: "{{OrderingKey}}", expected: "000000004_00000"},
+		{name: "attribute", template: "chain.{{Step}}", expected: "chain.Undo"},
+		{name: "missing attribute", template: "chain.{{Other}}", expectedErr: `template "chain.{{Other}}": message has no attribute "Other"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := NewTemplate(c.template).Render(message)
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, c.expected, out)
+		})
+	}
+}
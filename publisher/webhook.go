@@ -0,0 +1,393 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+const (
+	WebhookTopicHeader       = "X-Substreams-Topic"
+	WebhookCursorHeader      = "X-Substreams-Cursor"
+	WebhookBlockNumberHeader = "X-Substreams-Block-Number"
+	WebhookStepHeader        = "X-Substreams-Step"
+	WebhookMessageIDHeader   = "X-Substreams-Message-Id"
+	WebhookOrderingKeyHeader = "X-Substreams-Ordering-Key"
+	WebhookSignatureHeader   = "X-Substreams-Signature-256"
+	WebhookTimestampHeader   = "X-Substreams-Timestamp"
+
+	// WebhookAttributeHeaderPrefix prefixes the attributes sent as headers when posting each
+	// message individually.
+	WebhookAttributeHeaderPrefix = "X-Substreams-Attribute-"
+)
+
+// DefaultWebhookSignatureTolerance is the maximum age of a request's timestamp receivers should
+// accept, see [VerifyWebhookSignature].
+const DefaultWebhookSignatureTolerance = 5 * time.Minute
+
+// ErrInvalidWebhookSignature is returned by [VerifyWebhookSignature] for a request that was not
+// signed with the secret or whose timestamp is outside the tolerance.
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// WebhookConfig configures the [Webhook] publisher.
+type WebhookConfig struct {
+	// URL receives the POST requests.
+	URL string
+
+	// Topic is sent in the [WebhookTopicHeader] header of each request.
+	Topic string
+
+	// PerBlock posts all the messages published together, i.e. a block's messages, in a
+	// single JSON request instead of one request per message.
+	PerBlock bool
+
+	// Secret, when set, signs each request with HMAC-SHA256, see [WebhookSignature]. The hex
+	// encoded signature is sent in the [WebhookSignatureHeader] header as 'sha256=<signature>'
+	// and the signed Unix timestamp, in seconds, in the [WebhookTimestampHeader] header.
+	// Receivers check both with [VerifyWebhookSignature].
+	Secret []byte
+
+	// Timeout of each request attempt.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a failed request is retried, with exponential
+	// backoff, before failing the messages. Only network errors, 429 and 5xx responses
+	// are retried.
+	MaxRetries int
+
+	// Concurrency limits the number of requests in flight. Without PerBlock, the messages of a
+	// block are posted concurrently and may be delivered out of order, except the messages
+	// sharing an ordering key, see [Webhook].
+	Concurrency int
+}
+
+// WebhookMessage is the JSON representation of a message in the body of [WebhookConfig.PerBlock]
+// requests, data being base64 encoded.
+type WebhookMessage struct {
+	ID          string            `json:"id,omitempty"`
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"ordering_key,omitempty"`
+}
+
+// WebhookBatch is the JSON body of [WebhookConfig.PerBlock] requests.
+type WebhookBatch struct {
+	Messages []*WebhookMessage `json:"messages"`
+}
+
+// Webhook pushes messages to an HTTP endpoint with POST requests. A request is considered
+// delivered when the endpoint answers with a 2xx status code.
+//
+// The [WebhookCursorHeader], [WebhookBlockNumberHeader] and [WebhookStepHeader] headers are
+// taken from the attributes of the (first) message of the request, so that receivers can
+// route and de-duplicate without decoding the body.
+//
+// When posting each message individually, the messages sharing an ordering key are posted one
+// after the other, in the order they are published, the key being sent in the
+// [WebhookOrderingKeyHeader] header. Once one of them fails, the following ones fail too.
+type Webhook struct {
+	client *http.Client
+	config WebhookConfig
+
+	slots    chan struct{}
+	inflight sync.WaitGroup
+
+	// ordering holds the result of the last message posted with each ordering key.
+	orderingLock sync.Mutex
+	ordering     map[string]*asyncResult
+}
+
+func NewWebhook(config WebhookConfig) (*Webhook, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+
+	return &Webhook{
+		client:   &http.Client{Timeout: config.Timeout},
+		config:   config,
+		slots:    make(chan struct{}, config.Concurrency),
+		ordering: map[string]*asyncResult{},
+	}, nil
+}
+
+func (p *Webhook) Publish(ctx context.Context, messages []*pubsub.Message) []Result {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if !p.config.PerBlock {
+		results := make([]Result, 0, len(messages))
+		for _, message := range messages {
+			results = append(results, p.post(ctx, []*pubsub.Message{message}))
+		}
+		return results
+	}
+
+	result := p.post(ctx, messages)
+	results := make([]Result, len(messages))
+	for i := range messages {
+		results[i] = result
+	}
+
+	return results
+}
+
+func (p *Webhook) post(ctx context.Context, messages []*pubsub.Message) Result {
+	body, header, err := p.request(messages)
+	if err != nil {
+		return resolvedResult("", err)
+	}
+
+	result := newAsyncResult()
+
+	var previous *asyncResult
+	orderingKey := header.Get(WebhookOrderingKeyHeader)
+	if orderingKey != "" {
+		p.orderingLock.Lock()
+		previous = p.ordering[orderingKey]
+		p.ordering[orderingKey] = result
+		p.orderingLock.Unlock()
+	}
+
+	p.inflight.Add(1)
+	go func() {
+		defer p.inflight.Done()
+
+		if orderingKey != "" {
+			defer p.releaseOrderingKey(orderingKey, result)
+		}
+
+		if previous != nil {
+			if _, err := previous.Get(ctx); err != nil {
+				result.set("", fmt.Errorf("previous message with ordering key %q not delivered: %w", orderingKey, err))
+				return
+			}
+		}
+
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			result.set("", ctx.Err())
+			return
+		}
+		defer func() { <-p.slots }()
+
+		err := p.postWithRetries(ctx, body, header)
+		result.set(header.Get(WebhookMessageIDHeader), err)
+	}()
+
+	return result
+}
+
+// releaseOrderingKey forgets the ordering key once result is resolved, unless another message
+// was posted with it since.
+func (p *Webhook) releaseOrderingKey(orderingKey string, result *asyncResult) {
+	p.orderingLock.Lock()
+	defer p.orderingLock.Unlock()
+
+	if p.ordering[orderingKey] == result {
+		delete(p.ordering, orderingKey)
+	}
+}
+
+func (p *Webhook) request(messages []*pubsub.Message) (body []byte, header http.Header, err error) {
+	header = http.Header{}
+	header.Set(WebhookTopicHeader, p.config.Topic)
+
+	first := messages[0]
+	if cursor := first.Attributes["Cursor"]; cursor != "" {
+		header.Set(WebhookCursorHeader, cursor)
+
+		if c := messageCursor(first); c != nil {
+			header.Set(WebhookBlockNumberHeader, strconv.FormatUint(c.Block().Num(), 10))
+		}
+	}
+	if step := first.Attributes["Step"]; step != "" {
+		header.Set(WebhookStepHeader, step)
+	}
+
+	if p.config.PerBlock {
+		batch := &WebhookBatch{}
+		for _, message := range messages {
+			batch.Messages = append(batch.Messages, &WebhookMessage{
+				ID:          message.ID,
+				Data:        message.Data,
+				Attributes:  message.Attributes,
+				OrderingKey: message.OrderingKey,
+			})
+		}
+
+		if body, err = json.Marshal(batch); err != nil {
+			return nil, nil, fmt.Errorf("encoding messages: %w", err)
+		}
+		header.Set("Content-Type", "application/json")
+	} else {
+		body = first.Data
+		header.Set("Content-Type", "application/octet-stream")
+		if first.ID != "" {
+			header.Set(WebhookMessageIDHeader, first.ID)
+		}
+		if first.OrderingKey != "" {
+			if !validHeaderValue(first.OrderingKey) {
+				return nil, nil, fmt.Errorf("ordering key %q is not a valid header value", first.OrderingKey)
+			}
+			header.Set(WebhookOrderingKeyHeader, first.OrderingKey)
+		}
+		for key, value := range first.Attributes {
+			if !validHeaderName(key) {
+				return nil, nil, fmt.Errorf("attribute %q is not a valid header name, it must only contain letters, digits and !#$%%&'*+-.^_`|~", key)
+			}
+			if !validHeaderValue(value) {
+				return nil, nil, fmt.Errorf("attribute %q value is not a valid header value, it must not contain control characters", key)
+			}
+			header.Set(WebhookAttributeHeaderPrefix+key, value)
+		}
+	}
+
+	return body, header, nil
+}
+
+func (p *Webhook) postWithRetries(ctx context.Context, body []byte, header http.Header) error {
+	delay := 250 * time.Millisecond
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		if retryable, err = p.doPost(ctx, body, header); err == nil {
+			return nil
+		}
+
+		if !retryable || attempt >= p.config.MaxRetries {
+			return fmt.Errorf("posting to %q (attempt %d): %w", p.config.URL, attempt+1, err)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+func (p *Webhook) doPost(ctx context.Context, body []byte, header http.Header) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header = header.Clone()
+
+	// Signed on each attempt, so that a retried request is not rejected as too old
+	if len(p.config.Secret) > 0 {
+		timestamp := time.Now().Unix()
+		req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(WebhookSignatureHeader, "sha256="+WebhookSignature(p.config.Secret, timestamp, body))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+func (p *Webhook) Flush(ctx context.Context) error {
+	return waitGroupContext(ctx, &p.inflight)
+}
+
+func (p *Webhook) Close() error {
+	p.inflight.Wait()
+	p.client.CloseIdleConnections()
+
+	return nil
+}
+
+// validHeaderName returns true if name is an HTTP token, see RFC 9110 section 5.1.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, c := range []byte(name) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// validHeaderValue returns true if value has no control character other than tab, see RFC
+// 9110 section 5.5.
+func validHeaderValue(value string) bool {
+	for _, c := range []byte(value) {
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WebhookSignature returns the hex encoded HMAC-SHA256 with secret of '<timestamp>.<body>',
+// timestamp being the Unix time in seconds sent in the [WebhookTimestampHeader] header.
+func WebhookSignature(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature returns [ErrInvalidWebhookSignature] unless the [WebhookSignatureHeader]
+// header of a request is the signature of its body and [WebhookTimestampHeader] header with
+// secret, and the timestamp is within tolerance of now, see [DefaultWebhookSignatureTolerance].
+// Rejecting old timestamps keeps a captured request from being replayed later, receivers
+// de-duplicate the requests replayed within the window by message ID or cursor.
+func VerifyWebhookSignature(secret []byte, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrInvalidWebhookSignature, header.Get(WebhookTimestampHeader))
+	}
+
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp %d is outside the %s tolerance", ErrInvalidWebhookSignature, timestamp, tolerance)
+	}
+
+	expected := "sha256=" + WebhookSignature(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(WebhookSignatureHeader))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidWebhookSignature)
+	}
+
+	return nil
+}
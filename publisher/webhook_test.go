@@ -0,0 +1,231 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func TestWebhookPublish(t *testing.T) {
+	ctx := context.Background()
+	cursor := testCursor(4, "4a")
+
+	messages := []*pubsub.Message{
+		{
+			ID:         "4-4a-0",
+			Data:       []byte("data.1"),
+			Attributes: map[string]string{"Cursor": cursor, "key1": "value1"},
+		},
+		{
+			ID:         "4-4a-1",
+			Data:       []byte("data.2"),
+			Attributes: map[string]string{"Cursor": cursor},
+		},
+	}
+
+	t.Run("per message", func(t *testing.T) {
+		requests, srv := webhookServer(t, 0)
+
+		publisher, err := NewWebhook(WebhookConfig{URL: srv.URL, Topic: "topic", Secret: []byte("secret"), Concurrency: 2})
+		require.NoError(t, err)
+
+		for _, result := range publisher.Publish(ctx, messages) {
+			_, err := result.Get(ctx)
+			require.NoError(t, err)
+		}
+		require.NoError(t, publisher.Close())
+
+		require.Len(t, *requests, 2)
+		for _, request := range *requests {
+			require.Equal(t, "topic", request.header.Get(WebhookTopicHeader))
+			require.Equal(t, cursor, request.header.Get(WebhookCursorHeader))
+			require.Equal(t, "4", request.header.Get(WebhookBlockNumberHeader))
+			require.NoError(t, VerifyWebhookSignature([]byte("secret"), request.header, request.body, DefaultWebhookSignatureTolerance, time.Now()))
+
+			if request.header.Get(WebhookMessageIDHeader) == "4-4a-0" {
+				require.Equal(t, "data.1", string(request.body))
+				require.Equal(t, "value1", request.header.Get(WebhookAttributeHeaderPrefix+"key1"))
+			}
+		}
+	})
+
+	t.Run("per block", func(t *testing.T) {
+		requests, srv := webhookServer(t, 0)
+
+		publisher, err := NewWebhook(WebhookConfig{URL: srv.URL, Topic: "topic", PerBlock: true})
+		require.NoError(t, err)
+
+		for _, result := range publisher.Publish(ctx, messages) {
+			_, err := result.Get(ctx)
+			require.NoError(t, err)
+		}
+		require.NoError(t, publisher.Close())
+
+		require.Len(t, *requests, 1)
+		require.Equal(t, "4", (*requests)[0].header.Get(WebhookBlockNumberHeader))
+		require.Empty(t, (*requests)[0].header.Get(WebhookSignatureHeader))
+		require.Empty(t, (*requests)[0].header.Get(WebhookTimestampHeader))
+
+		batch := &WebhookBatch{}
+		require.NoError(t, json.Unmarshal((*requests)[0].body, batch))
+		require.Equal(t, &WebhookBatch{Messages: []*WebhookMessage{
+			{ID: "4-4a-0", Data: []byte("data.1"), Attributes: map[string]string{"Cursor": cursor, "key1": "value1"}},
+			{ID: "4-4a-1", Data: []byte("data.2"), Attributes: map[string]string{"Cursor": cursor}},
+		}}, batch)
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		requests, srv := webhookServer(t, 2)
+
+		publisher, err := NewWebhook(WebhookConfig{URL: srv.URL, MaxRetries: 2, Timeout: time.Second})
+		require.NoError(t, err)
+
+		_, err = publisher.Publish(ctx, messages[:1])[0].Get(ctx)
+		require.NoError(t, err)
+		require.Len(t, *requests, 3)
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		_, srv := webhookServer(t, 2)
+
+		publisher, err := NewWebhook(WebhookConfig{URL: srv.URL, MaxRetries: 1, Timeout: time.Second})
+		require.NoError(t, err)
+
+		_, err = publisher.Publish(ctx, messages[:1])[0].Get(ctx)
+		require.EqualError(t, err, `posting to "`+srv.URL+`" (attempt 2): unexpected status 503 Service Unavailable`)
+	})
+
+	t.Run("ordering key", func(t *testing.T) {
+		requests, srv := webhookServer(t, 0)
+
+		publisher, err := NewWebhook(WebhookConfig{URL: srv.URL, Concurrency: 8})
+		require.NoError(t, err)
+
+		var ordered []*pubsub.Message
+		for i := 0; i < 20; i++ {
+			ordered = append(ordered, &pubsub.Message{ID: strconv.Itoa(i), Data: []byte("data"), OrderingKey: "key"})
+		}
+
+		for _, result := range publisher.Publish(ctx, ordered) {
+			_, err := result.Get(ctx)
+			require.NoError(t, err)
+		}
+		require.NoError(t, publisher.Close())
+
+		require.Len(t, *requests, 20)
+		for i, request := range *requests {
+			require.Equal(t, strconv.Itoa(i), request.header.Get(WebhookMessageIDHeader))
+			require.Equal(t, "key", request.header.Get(WebhookOrderingKeyHeader))
+		}
+		require.Empty(t, publisher.ordering)
+	})
+
+	t.Run("ordering key failed", func(t *testing.T) {
+		requests, srv := webhookServer(t, 1)
+
+		publisher, err := NewWebhook(WebhookConfig{URL: srv.URL, Concurrency: 2})
+		require.NoError(t, err)
+
+		results := publisher.Publish(ctx, []*pubsub.Message{
+			{ID: "0", OrderingKey: "key"},
+			{ID: "1", OrderingKey: "key"},
+		})
+
+		_, err = results[0].Get(ctx)
+		require.ErrorContains(t, err, "unexpected status 503")
+		_, err = results[1].Get(ctx)
+		require.ErrorContains(t, err, `previous message with ordering key "key" not delivered`)
+		require.NoError(t, publisher.Close())
+		require.Len(t, *requests, 1)
+	})
+
+	t.Run("invalid attribute", func(t *testing.T) {
+		requests, srv := webhookServer(t, 0)
+
+		publisher, err := NewWebhook(WebhookConfig{URL: srv.URL, MaxRetries: 10})
+		require.NoError(t, err)
+
+		_, err = publisher.Publish(ctx, []*pubsub.Message{{Attributes: map[string]string{"event type": "transfer"}}})[0].Get(ctx)
+		require.ErrorContains(t, err, `attribute "event type" is not a valid header name`)
+
+		_, err = publisher.Publish(ctx, []*pubsub.Message{{Attributes: map[string]string{"type": "a\nb"}}})[0].Get(ctx)
+		require.ErrorContains(t, err, `attribute "type" value is not a valid header value`)
+		require.Empty(t, *requests)
+	})
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte("data.1")
+	now := time.Unix(1700000000, 0)
+
+	signed := func(timestamp int64) http.Header {
+		header := http.Header{}
+		header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+		header.Set(WebhookSignatureHeader, "sha256="+WebhookSignature(secret, timestamp, body))
+		return header
+	}
+
+	require.NoError(t, VerifyWebhookSignature(secret, signed(now.Unix()), body, time.Minute, now))
+	require.NoError(t, VerifyWebhookSignature(secret, signed(now.Unix()-60), body, time.Minute, now))
+
+	tests := []struct {
+		name   string
+		secret []byte
+		header http.Header
+		body   []byte
+	}{
+		{"wrong secret", []byte("other"), signed(now.Unix()), body},
+		{"tampered body", secret, signed(now.Unix()), []byte("data.2")},
+		{"too old", secret, signed(now.Unix() - 61), body},
+		{"in the future", secret, signed(now.Unix() + 61), body},
+		{"missing timestamp", secret, http.Header{WebhookSignatureHeader: signed(now.Unix())[WebhookSignatureHeader]}, body},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.ErrorIs(t, VerifyWebhookSignature(test.secret, test.header, test.body, time.Minute, now), ErrInvalidWebhookSignature)
+		})
+	}
+
+	t.Run("changed timestamp", func(t *testing.T) {
+		header := signed(now.Unix())
+		header.Set(WebhookTimestampHeader, strconv.FormatInt(now.Unix()-1, 10))
+		require.ErrorIs(t, VerifyWebhookSignature(secret, header, body, time.Minute, now), ErrInvalidWebhookSignature)
+	})
+}
+
+// webhookServer records received requests, answering 503 to the first failures ones.
+func webhookServer(t *testing.T, failures int) (*[]webhookRequest, *httptest.Server) {
+	var lock sync.Mutex
+	var requests []webhookRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests = append(requests, webhookRequest{header: r.Header, body: body})
+		if len(requests) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return &requests, srv
+}
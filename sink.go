@@ -2,49 +2,211 @@ package substreams_sink_pubsub
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/streamingfast/bstream"
 	"github.com/streamingfast/shutter"
 	sink "github.com/streamingfast/substreams-sink"
 	pbsubstreamsrpc "github.com/streamingfast/substreams/pb/sf/substreams/rpc/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 
 	pbpubsub "github.com/streamingfast/substreams-sink-pubsub/pb/sf/substreams/sink/pubsub/v1"
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
 )
 
+// Sink publishes the output of a Substreams module, a 'sf.substreams.sink.pubsub.v1.Publish'
+// message per block, to a [publisher.Publisher], see [New].
 type Sink struct {
 	*shutter.Shutter
 	*sink.Sinker
-	logger     *zap.Logger
-	client     *pubsub.Client
-	topic      *pubsub.Topic
-	cursorPath string
+	logger      *zap.Logger
+	publisher   publisher.Publisher
+	cursorStore CursorStore
+	dryRun      bool
+	attributes  map[string]string
+	idSuffix    string
+	health      *Health
+	transformer Transformer
+	filters     []Filter
+	hooks       Hooks
+	registerer  prometheus.Registerer
+	metrics     *metrics
+
+	// blockEnvelope publishes the messages of a block in a single [pbpubsub.BlockEnvelope].
+	blockEnvelope bool
+
+	// blockEndMarker publishes a 'Step=BlockEnd' message once a block's messages are acknowledged.
+	blockEndMarker bool
+
+	// heartbeatInterval is the time without published message after which a heartbeat is
+	// published, zero disabling heartbeats. lastPublishAt is only accessed by the handlers.
+	heartbeatInterval time.Duration
+	lastPublishAt     time.Time
+
+	// The cursor of handled blocks is saved in checkpoints, see [Sink.SetCheckpoint]. The pending
+	// fields are guarded by checkpointLock, the handlers, the checkpoint interval's ticker and
+	// [Sink.drain] saving concurrently. pendingBlocks only counts blocks with messages.
+	checkpointBlocks   int
+	checkpointInterval time.Duration
+	checkpointLock     sync.Mutex
+	pendingCursor      *sink.Cursor
+	pendingBlocks      int
+	checkpointAt       time.Time
+
+	drainTimeout  time.Duration
+	drainCtx      context.Context
+	stopStream    context.CancelFunc
+	publishCtx    context.Context
+	cancelPublish context.CancelFunc
+	draining      atomic.Bool
+	inFlight      atomic.Int64
+	lastCursor    atomic.Pointer[sink.Cursor]
 }
 
-type Message struct {
-	Data        []byte
-	Attributes  map[string]string
-	OrderingKey string
+// DefaultDrainTimeout is the time given to in-flight messages to be acknowledged when the sink
+// stops, see [Sink.SetDrainTimeout].
+const DefaultDrainTimeout = 30 * time.Second
+
+// New creates the sink, [WithSinker] and [WithPublisher] are required, and [WithCursorStore]
+// or [WithCursorPath] unless [WithDryRun] is given.
+func New(opts ...Option) (*Sink, error) {
+	s := newSink()
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.Sinker == nil {
+		return nil, fmt.Errorf("a sinker is required, see WithSinker")
+	}
+	if s.publisher == nil {
+		return nil, fmt.Errorf("a publisher is required, see WithPublisher")
+	}
+	if s.cursorStore == nil && !s.dryRun {
+		return nil, fmt.Errorf("a cursor store is required unless in dry run, see WithCursorStore")
+	}
+
+	if s.registerer != nil {
+		var err error
+		if s.metrics, err = newMetrics(s.registerer); err != nil {
+			return nil, fmt.Errorf("registering metrics: %w", err)
+		}
+	}
+
+	return s, nil
 }
 
-func NewSink(sinker *sink.Sinker, logger *zap.Logger, cursorPath string, client *pubsub.Client, topic *pubsub.Topic) *Sink {
+// NewSink creates the sink, when dryRun is true the cursor is neither loaded nor saved so
+// that the sink processes the whole block range and leaves no trace of its progress.
+//
+// Deprecated: use [New] with [WithSinker], [WithLogger], [WithCursorPath], [WithPublisher]
+// and [WithDryRun].
+func NewSink(sinker *sink.Sinker, logger *zap.Logger, cursorPath string, publisher publisher.Publisher, dryRun bool) *Sink {
+	s := newSink()
+	s.Sinker = sinker
+	s.logger = logger
+	s.cursorStore = NewFileCursorStore(cursorPath)
+	s.publisher = publisher
+	s.dryRun = dryRun
+
+	return s
+}
+
+func newSink() *Sink {
 	s := &Sink{
-		Shutter:    shutter.New(),
-		Sinker:     sinker,
-		logger:     logger,
-		client:     client,
-		cursorPath: cursorPath,
-		topic:      topic,
+		Shutter:      shutter.New(),
+		logger:       zap.NewNop(),
+		drainTimeout: DefaultDrainTimeout,
 	}
 
+	// Publishing is not bound to the stream's context, so that the block being handled when the
+	// stream stops is still published and its cursor saved.
+	s.drainCtx, s.stopStream = context.WithCancel(context.Background())
+	s.publishCtx, s.cancelPublish = context.WithCancel(context.Background())
+
 	return s
 }
 
+// SetAttributes adds the attributes to every message published by the sink, overriding the
+// module's attributes of the same name.
+func (s *Sink) SetAttributes(attributes map[string]string) {
+	s.attributes = attributes
+}
+
+// SetMessageIDSuffix appends suffix to the ID of every message published by the sink, so that
+// destinations de-duplicating by ID, like NATS JetStream, don't drop the messages of a block
+// published again on purpose, e.g. by a replay.
+func (s *Sink) SetMessageIDSuffix(suffix string) {
+	s.idSuffix = suffix
+}
+
+// SetBlockEnvelope makes the sink publish all the messages of a block in a single
+// [pbpubsub.BlockEnvelope] message instead of one message each, see
+// [generateBlockEnvelopeMessages].
+func (s *Sink) SetBlockEnvelope(enabled bool) {
+	s.blockEnvelope = enabled
+}
+
+// SetBlockEndMarker makes the sink publish a 'Step=BlockEnd' marker once the messages of a block
+// are acknowledged, see [generateBlockEndMessage].
+func (s *Sink) SetBlockEndMarker(enabled bool) {
+	s.blockEndMarker = enabled
+}
+
+// SetHeartbeatInterval makes the sink publish a 'Step=Heartbeat' message when no message was
+// published for interval, so that consumers can tell a stalled sink from a module emitting
+// nothing, see [generateHeartbeatMessage]. Heartbeats are checked when a block is handled.
+func (s *Sink) SetHeartbeatInterval(interval time.Duration) {
+	s.heartbeatInterval = interval
+}
+
+// SetCheckpoint makes the sink save its cursor once blocks blocks with messages were handled or
+// interval elapsed since the last save, whichever comes first, zero disabling either. The
+// interval is also checked while no block arrives. The cursor is always saved after an undo
+// signal and when the sink stops. When both are zero, the default, the cursor is saved after
+// every block with messages. Blocks without messages never trigger a save on their own, their
+// cursor is saved along with the next checkpoint. Blocks handled since the last save are
+// handled again on restart after a crash, publishing their messages again.
+func (s *Sink) SetCheckpoint(blocks int, interval time.Duration) {
+	s.checkpointBlocks = blocks
+	s.checkpointInterval = interval
+}
+
+// SetHealth makes the sink report its state to health.
+func (s *Sink) SetHealth(health *Health) {
+	s.health = health
+}
+
+// SetFencingToken makes the sink stop with [ErrFenced] when saving its cursor after a leader
+// with a higher fencing token was elected. It has no effect unless the cursor store is a
+// [FileCursorStore].
+func (s *Sink) SetFencingToken(token uint64) {
+	if store, ok := s.cursorStore.(*FileCursorStore); ok {
+		store.SetFencingToken(token)
+	}
+}
+
+// SetDrainTimeout sets the time given to the block being handled and to in-flight messages to
+// be acknowledged once the sink stops, see [Sink.Drain].
+func (s *Sink) SetDrainTimeout(timeout time.Duration) {
+	s.drainTimeout = timeout
+}
+
+// Run streams the module's output until the stop block, an error or [Sink.Drain]. It then
+// flushes the publisher and closes it, giving in-flight messages the drain timeout to be
+// acknowledged.
 func (s *Sink) Run(ctx context.Context) {
 	s.Sinker.OnTerminating(s.Shutdown)
 	s.OnTerminating(func(err error) {
@@ -52,13 +214,88 @@ func (s *Sink) Run(ctx context.Context) {
 		s.Sinker.Shutdown(err)
 	})
 
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	defer context.AfterFunc(s.drainCtx, cancelStream)()
+	defer s.cancelPublish()
+
 	cursor, err := s.loadCursor()
 	if err != nil {
 		s.Shutdown(fmt.Errorf("loading cursor: %w", err))
+	} else {
+		s.health.markCursorLoaded()
+	}
+
+	if s.checkpointInterval > 0 {
+		go s.runCheckpointTicker(streamCtx)
 	}
 
 	s.logger.Info("starting PubSub sink", zap.Stringer("restarting_at", cursor.Block()))
-	s.Sinker.Run(ctx, cursor, sink.NewSinkerHandlers(s.handleBlockScopedData, s.handleBlockUndoSignal))
+	s.Sinker.Run(streamCtx, cursor, sink.NewSinkerHandlers(s.handleBlockScopedData, s.handleBlockUndoSignal))
+
+	s.drain()
+}
+
+// Drain stops taking new blocks. The block being handled is published and its cursor saved,
+// then [Sink.Run] flushes and closes the publisher before returning. Publishing is aborted
+// if it takes longer than the drain timeout. When called before [Sink.Run], the sink stops
+// as soon as it starts.
+func (s *Sink) Drain() {
+	if !s.draining.CompareAndSwap(false, true) {
+		return
+	}
+
+	s.logger.Info("draining, no new block will be handled",
+		zap.Duration("drain_timeout", s.drainTimeout),
+		zap.Int64("in_flight_messages", s.inFlight.Load()),
+	)
+
+	s.health.markDraining()
+	time.AfterFunc(s.drainTimeout, s.cancelPublish)
+	s.stopStream()
+}
+
+// drain saves the pending checkpoint, whose blocks are all acknowledged, then flushes the
+// publisher, bounded by the drain timeout, and closes it.
+func (s *Sink) drain() {
+	if err := s.saveCheckpoint(); err != nil {
+		s.logger.Warn("saving cursor checkpoint, the blocks handled since the last one will be published again on restart", zap.Error(err))
+	}
+
+	start := time.Now()
+	inFlight := s.inFlight.Load()
+
+	ctx, cancel := context.WithTimeout(s.publishCtx, s.drainTimeout)
+	defer cancel()
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- s.publisher.Flush(ctx) }()
+
+	var flushErr error
+	select {
+	case flushErr = <-flushed:
+	case <-ctx.Done():
+		flushErr = ctx.Err()
+	}
+
+	fields := []zap.Field{
+		zap.Int64("flushed_messages", inFlight-s.inFlight.Load()),
+		zap.Int64("unacknowledged_messages", s.inFlight.Load()),
+		zap.Duration("elapsed", time.Since(start)),
+	}
+	if cursor := s.lastCursor.Load(); cursor != nil {
+		fields = append(fields, zap.Stringer("last_saved_block", cursor.Block()))
+	}
+
+	if flushErr != nil {
+		s.logger.Warn("publisher flush failed, unacknowledged messages will be published again on restart", append(fields, zap.Error(flushErr))...)
+	} else {
+		s.logger.Info("publisher flushed", fields...)
+	}
+
+	if err := s.publisher.Close(); err != nil {
+		s.logger.Warn("closing publisher", zap.Error(err))
+	}
 }
 
 func (s *Sink) handleBlockScopedData(ctx context.Context, data *pbsubstreamsrpc.BlockScopedData, isLive *bool, cursor *sink.Cursor) error {
@@ -71,21 +308,90 @@ func (s *Sink) handleBlockScopedData(ctx context.Context, data *pbsubstreamsrpc.
 	}
 
 	blockNum := data.Clock.Number
-	messages := generateBlockScopedMessages(publish, cursor, blockNum)
+	block := newBlockContext(data.Clock, cursor, isLive)
+
+	var messages []*pubsub.Message
+	if s.blockEnvelope {
+		messages, err = generateBlockEnvelopeMessages(publish, cursor, data.Clock, envelopeChunkSize)
+		if err != nil {
+			return fmt.Errorf("generating block envelope: %w", err)
+		}
+	} else {
+		messages = generateBlockScopedMessages(publish, cursor, blockNum)
+	}
 
-	err = s.publishMessages(ctx, messages)
+	messages, err = s.transform(ctx, block, messages)
+	if err != nil {
+		return fmt.Errorf("transforming messages: %w", err)
+	}
+
+	err = s.publishMessages(s.publishCtx, messages)
 	if err != nil {
 		return fmt.Errorf("publishing messages: %w", err)
 	}
 
-	err = s.saveCursor(cursor)
+	if s.blockEndMarker && len(messages) > 0 {
+		err = s.publishMessages(s.publishCtx, []*pubsub.Message{generateBlockEndMessage(messages, cursor, blockNum)})
+		if err != nil {
+			return fmt.Errorf("publishing block end marker: %w", err)
+		}
+	}
+
+	if len(messages) > 0 {
+		s.lastPublishAt = time.Now()
+	}
+
+	if s.heartbeatDue() {
+		err = s.publishMessages(s.publishCtx, []*pubsub.Message{generateHeartbeatMessage(cursor, data.Clock, isLive)})
+		if err != nil {
+			return fmt.Errorf("publishing heartbeat: %w", err)
+		}
+		s.lastPublishAt = time.Now()
+	}
+
+	if s.hooks.OnBlock != nil {
+		s.hooks.OnBlock(ctx, block, messages)
+	}
+
+	err = s.checkpoint(cursor, len(messages) > 0, false)
 	if err != nil {
 		return fmt.Errorf("saving cursor: %w", err)
 	}
 
+	s.metrics.markBlockHandled(blockNum)
+	s.health.markBlockHandled()
 	return nil
 }
 
+// transform applies the filters, then the transformer, to the block's messages.
+func (s *Sink) transform(ctx context.Context, block *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+	transformer := s.transformer
+	if len(s.filters) > 0 {
+		transformer = Chain(FilterMessages(s.filters...), s.transformer)
+	}
+
+	if transformer == nil {
+		return messages, nil
+	}
+
+	return transformer(ctx, block, messages)
+}
+
+// heartbeatDue returns true if no message was published for the heartbeat interval, the first
+// interval starting with the first handled block.
+func (s *Sink) heartbeatDue() bool {
+	if s.heartbeatInterval <= 0 {
+		return false
+	}
+
+	if s.lastPublishAt.IsZero() {
+		s.lastPublishAt = time.Now()
+		return false
+	}
+
+	return time.Since(s.lastPublishAt) >= s.heartbeatInterval
+}
+
 func generateBlockScopedMessages(publish *pbpubsub.Publish, cursor *sink.Cursor, blockNum uint64) []*pubsub.Message {
 	var messages []*pubsub.Message
 	var indexCounter int
@@ -98,6 +404,7 @@ func generateBlockScopedMessages(publish *pbpubsub.Publish, cursor *sink.Cursor,
 		attributes["Cursor"] = cursor.String()
 
 		msg := &pubsub.Message{
+			ID:         messageID(blockNum, cursor.Block().ID(), indexCounter),
 			Data:       message.Data,
 			Attributes: attributes,
 		}
@@ -109,81 +416,287 @@ func generateBlockScopedMessages(publish *pbpubsub.Publish, cursor *sink.Cursor,
 	return messages
 }
 
+// envelopeChunkSize is the maximum data size of a block envelope message, leaving room under
+// [publisher.PubSubMaxMessageSize] for the attributes.
+const envelopeChunkSize = 9 * 1000 * 1000
+
+// generateBlockEnvelopeMessages wraps the block's messages in a [pbpubsub.BlockEnvelope]. The
+// serialized envelope is split in chunks of at most chunkSize bytes, each published as a message
+// with the 'EnvelopeChunk' (starting at 0) and 'EnvelopeChunks' attributes, to be concatenated
+// in order before being unmarshalled. They differ from the [Chunk] transformer's attributes, so
+// that it can split envelope chunks further. No message is published for a block without
+// messages.
+func generateBlockEnvelopeMessages(publish *pbpubsub.Publish, cursor *sink.Cursor, clock *pbsubstreams.Clock, chunkSize int) ([]*pubsub.Message, error) {
+	if len(publish.Messages) == 0 {
+		return nil, nil
+	}
+
+	step := "New"
+	if cursor.Step.Matches(bstream.StepIrreversible) {
+		step = "NewIrreversible"
+	}
+
+	data, err := proto.Marshal(&pbpubsub.BlockEnvelope{
+		BlockNumber: clock.Number,
+		BlockId:     clock.Id,
+		Timestamp:   clock.Timestamp,
+		Cursor:      cursor.String(),
+		Step:        step,
+		Messages:    publish.Messages,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling envelope: %w", err)
+	}
+
+	chunks := (len(data) + chunkSize - 1) / chunkSize
+
+	messages := make([]*pubsub.Message, 0, chunks)
+	for i := 0; i < chunks; i++ {
+		end := min((i+1)*chunkSize, len(data))
+
+		attributes := map[string]string{
+			"Cursor":   cursor.String(),
+			"Envelope": "block",
+		}
+		if chunks > 1 {
+			attributes["EnvelopeChunk"] = strconv.Itoa(i)
+			attributes["EnvelopeChunks"] = strconv.Itoa(chunks)
+		}
+
+		messages = append(messages, &pubsub.Message{
+			ID:         messageID(clock.Number, clock.Id, i),
+			Data:       data[i*chunkSize : end],
+			Attributes: attributes,
+		})
+	}
+
+	return messages, nil
+}
+
+// generateBlockEndMessage returns the marker published after the block's messages, with the
+// 'Step=BlockEnd', 'BlockNumber', 'MessageCount', 'ContentHash' and 'Cursor' attributes and the
+// ordering key of the block's last message. Its ID '<block>-<id>-end' never matches the one of
+// a message of the block, even when transformers dropped some of them. The content hash is the
+// hex encoded SHA-256 of the messages' data, in order, each prefixed by its length as a
+// big-endian uint64.
+func generateBlockEndMessage(messages []*pubsub.Message, cursor *sink.Cursor, blockNum uint64) *pubsub.Message {
+	hash := sha256.New()
+	for _, message := range messages {
+		hash.Write(binary.BigEndian.AppendUint64(nil, uint64(len(message.Data))))
+		hash.Write(message.Data)
+	}
+
+	var orderingKey string
+	if len(messages) > 0 {
+		orderingKey = messages[len(messages)-1].OrderingKey
+	}
+
+	return &pubsub.Message{
+		ID: fmt.Sprintf("%d-%s-end", blockNum, cursor.Block().ID()),
+		Attributes: map[string]string{
+			"Step":         "BlockEnd",
+			"BlockNumber":  strconv.FormatUint(blockNum, 10),
+			"MessageCount": strconv.Itoa(len(messages)),
+			"ContentHash":  hex.EncodeToString(hash.Sum(nil)),
+			"Cursor":       cursor.String(),
+		},
+		OrderingKey: orderingKey,
+	}
+}
+
+// generateHeartbeatMessage returns a message without data with the 'Step=Heartbeat',
+// 'BlockNumber', 'BlockID', 'BlockTimestamp' (RFC 3339), 'Live' and 'Cursor' attributes of the
+// latest handled block.
+func generateHeartbeatMessage(cursor *sink.Cursor, clock *pbsubstreams.Clock, isLive *bool) *pubsub.Message {
+	live := isLive != nil && *isLive
+
+	return &pubsub.Message{
+		Attributes: map[string]string{
+			"Step":           "Heartbeat",
+			"BlockNumber":    strconv.FormatUint(clock.Number, 10),
+			"BlockID":        clock.Id,
+			"BlockTimestamp": clock.Timestamp.AsTime().Format(time.RFC3339),
+			"Live":           strconv.FormatBool(live),
+			"Cursor":         cursor.String(),
+		},
+	}
+}
+
+// messageID is stable when a block is re-processed after a restart, it is carried in the
+// message's ID (ignored by PubSub on publish) for destinations supporting de-duplication.
+func messageID(blockNum uint64, blockID string, index int) string {
+	return fmt.Sprintf("%d-%s-%d", blockNum, blockID, index)
+}
+
 func (s *Sink) handleBlockUndoSignal(ctx context.Context, data *pbsubstreamsrpc.BlockUndoSignal, cursor *sink.Cursor) error {
 	lastValidBlockNum := data.LastValidBlock.Number
 
 	messages := generateUndoBlockMessages(lastValidBlockNum, cursor)
 
-	err := s.publishMessages(ctx, messages)
+	err := s.publishMessages(s.publishCtx, messages)
 	if err != nil {
 		return fmt.Errorf("publishing messages: %w", err)
 	}
+	s.lastPublishAt = time.Now()
 
-	err = s.saveCursor(cursor)
+	if s.hooks.OnUndo != nil {
+		s.hooks.OnUndo(ctx, lastValidBlockNum, cursor)
+	}
+
+	err = s.checkpoint(cursor, true, true)
 	if err != nil {
 		return fmt.Errorf("saving cursor: %w", err)
 	}
 
+	s.metrics.markUndo()
+	s.health.markBlockHandled()
 	return nil
 }
 
-func (s *Sink) loadCursor() (*sink.Cursor, error) {
-	fpath := filepath.Join(s.cursorPath, "cursor.json")
+// checkpoint records cursor as the cursor of the last handled block and saves it if a checkpoint
+// is due or force is true. published is false for a block without messages, which doesn't count
+// towards the checkpoint and only gets saved once the interval elapsed.
+func (s *Sink) checkpoint(cursor *sink.Cursor, published bool, force bool) error {
+	s.checkpointLock.Lock()
+	defer s.checkpointLock.Unlock()
 
-	_, err := os.Stat(fpath)
-	if os.IsNotExist(err) {
-		return nil, nil
+	s.pendingCursor = cursor
+	if published {
+		s.pendingBlocks++
+	}
+	if s.checkpointAt.IsZero() {
+		s.checkpointAt = time.Now()
+	}
+
+	if !force && !s.checkpointDue() {
+		return nil
 	}
 
-	cursorData, err := os.ReadFile(fpath)
+	return s.saveCheckpointLocked()
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("reading cursor file: %w", err)
+func (s *Sink) checkpointDue() bool {
+	if s.checkpointBlocks <= 0 && s.checkpointInterval <= 0 {
+		return s.pendingBlocks > 0
 	}
 
-	cursorString := string(cursorData)
-	cursor, err := sink.NewCursor(cursorString)
-	if err != nil {
-		return nil, fmt.Errorf("parsing cursor: %w", err)
+	if s.checkpointBlocks > 0 && s.pendingBlocks >= s.checkpointBlocks {
+		return true
 	}
 
-	return cursor, nil
+	return s.checkpointIntervalElapsed()
 }
 
-func (s *Sink) saveCursor(c *sink.Cursor) error {
-	cursorString := c.String()
+func (s *Sink) checkpointIntervalElapsed() bool {
+	return s.checkpointInterval > 0 && !s.checkpointAt.IsZero() && time.Since(s.checkpointAt) >= s.checkpointInterval
+}
 
-	err := os.MkdirAll(s.cursorPath, os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("making state store path: %w", err)
+// runCheckpointTicker saves the pending cursor once the checkpoint interval elapsed, so that it
+// also advances while no block arrives, until ctx is done. A failed save stops the sink.
+func (s *Sink) runCheckpointTicker(ctx context.Context) {
+	ticker := time.NewTicker(s.checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.checkpointLock.Lock()
+		var err error
+		if s.checkpointIntervalElapsed() {
+			err = s.saveCheckpointLocked()
+		}
+		s.checkpointLock.Unlock()
+
+		if err != nil {
+			s.Shutdown(fmt.Errorf("saving cursor: %w", err))
+			return
+		}
 	}
+}
 
-	fpath := filepath.Join(s.cursorPath, "cursor.json")
+// saveCheckpoint saves the pending cursor, if any.
+func (s *Sink) saveCheckpoint() error {
+	s.checkpointLock.Lock()
+	defer s.checkpointLock.Unlock()
 
-	err = os.WriteFile(fpath, []byte(cursorString), os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("writing cursor file: %w", err)
+	return s.saveCheckpointLocked()
+}
+
+func (s *Sink) saveCheckpointLocked() error {
+	if s.pendingCursor == nil {
+		return nil
+	}
+
+	if err := s.saveCursor(s.pendingCursor); err != nil {
+		return err
 	}
 
+	s.pendingCursor = nil
+	s.pendingBlocks = 0
+	s.checkpointAt = time.Now()
 	return nil
 }
 
-func (s *Sink) publishMessages(ctx context.Context, messages []*pubsub.Message) error {
-	var results []*pubsub.PublishResult
+func (s *Sink) loadCursor() (*sink.Cursor, error) {
+	if s.dryRun {
+		return nil, nil
+	}
+
+	return s.cursorStore.Load()
+}
+
+func (s *Sink) saveCursor(c *sink.Cursor) error {
+	if s.dryRun {
+		return nil
+	}
+
+	if err := s.cursorStore.Save(c); err != nil {
+		return err
+	}
+
+	s.lastCursor.Store(c)
+	s.metrics.markCursorSaved(c.Block().Num())
+	if s.hooks.OnCursorSaved != nil {
+		s.hooks.OnCursorSaved(c)
+	}
+	return nil
+}
 
+func (s *Sink) publishMessages(ctx context.Context, messages []*pubsub.Message) error {
 	for _, message := range messages {
-		result := s.topic.Publish(ctx, message)
-		results = append(results, result)
+		if message.Attributes == nil && len(s.attributes) > 0 {
+			message.Attributes = make(map[string]string, len(s.attributes))
+		}
+		for key, value := range s.attributes {
+			message.Attributes[key] = value
+		}
+		if s.idSuffix != "" && message.ID != "" && !strings.HasSuffix(message.ID, s.idSuffix) {
+			message.ID += s.idSuffix
+		}
 	}
 
+	s.inFlight.Add(int64(len(messages)))
+	results := s.publisher.Publish(ctx, messages)
+
 	meg := multierror.Group{}
-	for _, res := range results {
-		res := res
+	for i, res := range results {
+		res, message := res, messages[i]
 		meg.Go(func() error {
 			_, err := res.Get(ctx)
+			s.inFlight.Add(-1)
 			if err != nil {
+				s.health.markPublishErrors(1)
+				s.metrics.markPublishError()
+				if s.hooks.OnPublishError != nil {
+					s.hooks.OnPublishError(message, err)
+				}
 				return err
 			}
+			s.metrics.markPublished()
 			return nil
 		})
 	}
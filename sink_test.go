@@ -6,7 +6,10 @@ import (
 	"github.com/streamingfast/bstream"
 	sink "github.com/streamingfast/substreams-sink"
 	pbpubsub "github.com/streamingfast/substreams-sink-pubsub/pb/sf/substreams/sink/pubsub/v1"
+	"github.com/streamingfast/substreams-sink-pubsub/publisher"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -15,11 +18,14 @@ import (
 	"cloud.google.com/go/pubsub/pstest"
 	"github.com/streamingfast/logging"
 	"github.com/streamingfast/shutter"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var logger, _ = logging.ApplicationLogger("test", "test")
@@ -37,12 +43,11 @@ func TestHandleCursor(t *testing.T) {
 	}
 
 	testSink := &Sink{
-		Shutter:    shutter.New(),
-		Sinker:     nil,
-		logger:     logger,
-		client:     nil,
-		topic:      nil,
-		cursorPath: "/tmp/sink-sate",
+		Shutter:     shutter.New(),
+		Sinker:      nil,
+		logger:      logger,
+		publisher:   nil,
+		cursorStore: NewFileCursorStore("/tmp/sink-sate"),
 	}
 
 	err := testSink.saveCursor(cursor)
@@ -55,6 +60,33 @@ func TestHandleCursor(t *testing.T) {
 
 }
 
+func TestHandleCursorDryRun(t *testing.T) {
+	cursor := &sink.Cursor{
+		Cursor: &bstream.Cursor{
+			Step:      1,
+			Block:     bstream.NewBlockRefFromID("3"),
+			LIB:       bstream.NewBlockRefFromID("2"),
+			HeadBlock: bstream.NewBlockRefFromID("4"),
+		},
+	}
+
+	cursorPath := t.TempDir()
+	testSink := &Sink{
+		Shutter:     shutter.New(),
+		logger:      logger,
+		cursorStore: NewFileCursorStore(cursorPath),
+		dryRun:      true,
+	}
+
+	err := testSink.saveCursor(cursor)
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(cursorPath, "cursor.json"))
+
+	loadCursor, err := testSink.loadCursor()
+	require.NoError(t, err)
+	require.Nil(t, loadCursor)
+}
+
 type resultMessage struct {
 	data        string
 	attributes  map[string]string
@@ -66,6 +98,7 @@ func TestPublishMessages(t *testing.T) {
 
 	cases := []struct {
 		name            string
+		attributes      map[string]string
 		messages        []*pubsub.Message
 		expectedResults []resultMessage
 	}{
@@ -165,6 +198,33 @@ func TestPublishMessages(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "sink attributes",
+			attributes: map[string]string{"Replay": "true"},
+			messages: []*pubsub.Message{
+				{
+					Data:        []byte("data.1"),
+					OrderingKey: "1_1",
+				},
+				{
+					Data:        []byte("data.2"),
+					OrderingKey: "1_2",
+					Attributes:  map[string]string{"cursor": "3", "Replay": "false"},
+				},
+			},
+			expectedResults: []resultMessage{
+				{
+					data:        "data.1",
+					orderingKey: "1_1",
+					attributes:  map[string]string{"Replay": "true"},
+				},
+				{
+					data:        "data.2",
+					orderingKey: "1_2",
+					attributes:  map[string]string{"cursor": "3", "Replay": "true"},
+				},
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -192,13 +252,12 @@ func TestPublishMessages(t *testing.T) {
 				Shutter:    shutter.New(),
 				Sinker:     nil,
 				logger:     logger,
-				client:     client,
-				topic:      topic,
-				cursorPath: "",
+				publisher:  publisher.NewPubSub(client, topic),
+				attributes: c.attributes,
 			}
 
 			subscription, err := client.CreateSubscription(ctx, "sub", pubsub.SubscriptionConfig{
-				Topic:                 testSink.topic,
+				Topic:                 topic,
 				AckDeadline:           10 * time.Second,
 				EnableMessageOrdering: true,
 			})
@@ -287,6 +346,7 @@ func TestGenerateBlockScopedMessages(t *testing.T) {
 
 	expectedResults := []*pubsub.Message{
 		{
+			ID:   "4-3-0",
 			Data: []byte("data.1"),
 			Attributes: map[string]string{
 				"Cursor": "e_jb3d3LppwOzpSs-jtHy6WyLpcyBlBsXwvvLhtBj4k=",
@@ -295,6 +355,7 @@ func TestGenerateBlockScopedMessages(t *testing.T) {
 			OrderingKey: "000000004_00000",
 		},
 		{
+			ID:   "4-3-1",
 			Data: []byte("data.2"),
 			Attributes: map[string]string{
 				"Cursor": "e_jb3d3LppwOzpSs-jtHy6WyLpcyBlBsXwvvLhtBj4k=",
@@ -309,6 +370,257 @@ func TestGenerateBlockScopedMessages(t *testing.T) {
 	require.Equal(t, expectedResults, results)
 }
 
+func TestGenerateBlockEnvelopeMessages(t *testing.T) {
+	cursor := &sink.Cursor{
+		Cursor: &bstream.Cursor{
+			Step:      bstream.StepNewIrreversible,
+			Block:     bstream.NewBlockRefFromID("3"),
+			LIB:       bstream.NewBlockRefFromID("2"),
+			HeadBlock: bstream.NewBlockRefFromID("4"),
+		},
+	}
+
+	clock := &pbsubstreams.Clock{Number: 4, Id: "3", Timestamp: timestamppb.New(time.Unix(1700000000, 0))}
+	publish := &pbpubsub.Publish{
+		Messages: []*pbpubsub.Message{
+			{Data: []byte("data.1"), Attributes: []*pbpubsub.Attribute{{Key: "key1", Value: "value1"}}},
+			{Data: []byte("data.2")},
+		},
+	}
+
+	expected := &pbpubsub.BlockEnvelope{
+		BlockNumber: 4,
+		BlockId:     "3",
+		Timestamp:   clock.Timestamp,
+		Cursor:      cursor.String(),
+		Step:        "NewIrreversible",
+		Messages:    publish.Messages,
+	}
+
+	t.Run("single", func(t *testing.T) {
+		messages, err := generateBlockEnvelopeMessages(publish, cursor, clock, envelopeChunkSize)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, "4-3-0", messages[0].ID)
+		require.Equal(t, map[string]string{"Cursor": cursor.String(), "Envelope": "block"}, messages[0].Attributes)
+
+		envelope := &pbpubsub.BlockEnvelope{}
+		require.NoError(t, proto.Unmarshal(messages[0].Data, envelope))
+		require.True(t, proto.Equal(expected, envelope))
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		messages, err := generateBlockEnvelopeMessages(publish, cursor, clock, 16)
+		require.NoError(t, err)
+		require.Greater(t, len(messages), 1)
+
+		var data []byte
+		for i, message := range messages {
+			require.Equal(t, fmt.Sprintf("4-3-%d", i), message.ID)
+			require.Equal(t, strconv.Itoa(i), message.Attributes["EnvelopeChunk"])
+			require.Equal(t, strconv.Itoa(len(messages)), message.Attributes["EnvelopeChunks"])
+			require.LessOrEqual(t, len(message.Data), 16)
+			data = append(data, message.Data...)
+		}
+
+		envelope := &pbpubsub.BlockEnvelope{}
+		require.NoError(t, proto.Unmarshal(data, envelope))
+		require.True(t, proto.Equal(expected, envelope))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		messages, err := generateBlockEnvelopeMessages(&pbpubsub.Publish{}, cursor, clock, envelopeChunkSize)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+}
+
+func TestGenerateBlockEndMessage(t *testing.T) {
+	cursor := &sink.Cursor{
+		Cursor: &bstream.Cursor{
+			Step:      1,
+			Block:     bstream.NewBlockRefFromID("3"),
+			LIB:       bstream.NewBlockRefFromID("2"),
+			HeadBlock: bstream.NewBlockRefFromID("4"),
+		},
+	}
+
+	messages := []*pubsub.Message{
+		{Data: []byte("data.1"), OrderingKey: "key"},
+		{Data: []byte("data.2"), OrderingKey: "key"},
+	}
+
+	expected := &pubsub.Message{
+		ID: "4-3-end",
+		Attributes: map[string]string{
+			"Step":         "BlockEnd",
+			"BlockNumber":  "4",
+			"MessageCount": "2",
+			"ContentHash":  "8fa661e0bc4810a0d21f92428b3823941e873b6ae3750fc05ce4a547ef28c8c6",
+			"Cursor":       "e_jb3d3LppwOzpSs-jtHy6WyLpcyBlBsXwvvLhtBj4k=",
+		},
+		OrderingKey: "key",
+	}
+
+	require.Equal(t, expected, generateBlockEndMessage(messages, cursor, 4))
+
+	t.Run("unique id after a dropped message", func(t *testing.T) {
+		publish := &pbpubsub.Publish{Messages: []*pbpubsub.Message{
+			{Data: []byte("0")}, {Data: []byte("1")}, {Data: []byte("2")}, {Data: []byte("3")},
+		}}
+
+		generated := generateBlockScopedMessages(publish, cursor, 4)
+		kept, err := FilterMessages(func(_ *BlockContext, message *pubsub.Message) bool {
+			return string(message.Data) != "1"
+		})(context.Background(), &BlockContext{}, generated)
+		require.NoError(t, err)
+		require.Len(t, kept, 3)
+
+		marker := generateBlockEndMessage(kept, cursor, 4)
+		for _, message := range kept {
+			require.NotEqual(t, message.ID, marker.ID)
+		}
+	})
+}
+
+func TestGenerateHeartbeatMessage(t *testing.T) {
+	cursor := &sink.Cursor{
+		Cursor: &bstream.Cursor{
+			Step:      1,
+			Block:     bstream.NewBlockRefFromID("3"),
+			LIB:       bstream.NewBlockRefFromID("2"),
+			HeadBlock: bstream.NewBlockRefFromID("4"),
+		},
+	}
+
+	clock := &pbsubstreams.Clock{Number: 4, Id: "3", Timestamp: timestamppb.New(time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC))}
+	live := true
+
+	expected := &pubsub.Message{
+		Attributes: map[string]string{
+			"Step":           "Heartbeat",
+			"BlockNumber":    "4",
+			"BlockID":        "3",
+			"BlockTimestamp": "2023-11-14T22:13:20Z",
+			"Live":           "true",
+			"Cursor":         "e_jb3d3LppwOzpSs-jtHy6WyLpcyBlBsXwvvLhtBj4k=",
+		},
+	}
+
+	require.Equal(t, expected, generateHeartbeatMessage(cursor, clock, &live))
+	require.Equal(t, "false", generateHeartbeatMessage(cursor, clock, nil).Attributes["Live"])
+}
+
+func TestHeartbeatDue(t *testing.T) {
+	s := &Sink{}
+	require.False(t, s.heartbeatDue(), "heartbeats are disabled")
+
+	s.SetHeartbeatInterval(time.Minute)
+	require.False(t, s.heartbeatDue(), "first interval starts with the first block")
+	require.False(t, s.lastPublishAt.IsZero())
+	require.False(t, s.heartbeatDue())
+
+	s.lastPublishAt = time.Now().Add(-2 * time.Minute)
+	require.True(t, s.heartbeatDue())
+}
+
+func TestCheckpoint(t *testing.T) {
+	cursor := func(blockNum uint64) *sink.Cursor {
+		block := bstream.NewBlockRef(fmt.Sprintf("%da", blockNum), blockNum)
+		return &sink.Cursor{Cursor: &bstream.Cursor{Step: bstream.StepNew, Block: block, LIB: block, HeadBlock: block}}
+	}
+
+	newSink := func(blocks int, interval time.Duration) *Sink {
+		s := &Sink{logger: logger, cursorStore: NewFileCursorStore(t.TempDir()), publisher: &drainPublisher{}, drainTimeout: time.Second, publishCtx: context.Background()}
+		s.SetCheckpoint(blocks, interval)
+		return s
+	}
+
+	savedBlock := func(s *Sink) uint64 {
+		saved, err := s.loadCursor()
+		require.NoError(t, err)
+		if saved == nil {
+			return 0
+		}
+		return saved.Block().Num()
+	}
+
+	t.Run("every block", func(t *testing.T) {
+		s := newSink(0, 0)
+		require.NoError(t, s.checkpoint(cursor(1), true, false))
+		require.Equal(t, uint64(1), savedBlock(s))
+	})
+
+	t.Run("blocks", func(t *testing.T) {
+		s := newSink(3, 0)
+		require.NoError(t, s.checkpoint(cursor(1), true, false))
+		require.NoError(t, s.checkpoint(cursor(2), true, false))
+		require.Equal(t, uint64(0), savedBlock(s))
+
+		require.NoError(t, s.checkpoint(cursor(3), true, false))
+		require.Equal(t, uint64(3), savedBlock(s))
+
+		require.NoError(t, s.checkpoint(cursor(4), true, false))
+		require.Equal(t, uint64(3), savedBlock(s))
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		s := newSink(0, time.Minute)
+		require.NoError(t, s.checkpoint(cursor(1), true, false))
+		require.Equal(t, uint64(0), savedBlock(s))
+
+		s.checkpointAt = time.Now().Add(-2 * time.Minute)
+		require.NoError(t, s.checkpoint(cursor(2), true, false))
+		require.Equal(t, uint64(2), savedBlock(s))
+	})
+
+	t.Run("empty blocks", func(t *testing.T) {
+		store := &countingCursorStore{CursorStore: NewFileCursorStore(t.TempDir())}
+		s := newSink(0, 0)
+		s.cursorStore = store
+
+		for i := uint64(1); i <= 10; i++ {
+			require.NoError(t, s.checkpoint(cursor(i), false, false))
+		}
+		require.Equal(t, 0, store.saves)
+
+		require.NoError(t, s.checkpoint(cursor(11), true, false))
+		require.Equal(t, 1, store.saves)
+		require.Equal(t, uint64(11), savedBlock(s))
+
+		require.NoError(t, s.checkpoint(cursor(12), false, false))
+		require.Equal(t, 1, store.saves)
+
+		s.drain()
+		require.Equal(t, 2, store.saves)
+		require.Equal(t, uint64(12), savedBlock(s))
+	})
+
+	t.Run("interval without blocks", func(t *testing.T) {
+		s := newSink(0, 10*time.Millisecond)
+		require.NoError(t, s.checkpoint(cursor(1), false, false))
+		require.Equal(t, uint64(0), savedBlock(s))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.runCheckpointTicker(ctx)
+
+		require.Eventually(t, func() bool { return savedBlock(s) == 1 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("forced and on drain", func(t *testing.T) {
+		s := newSink(100, 0)
+		require.NoError(t, s.checkpoint(cursor(1), true, true))
+		require.Equal(t, uint64(1), savedBlock(s))
+
+		require.NoError(t, s.checkpoint(cursor(2), true, false))
+		require.Equal(t, uint64(1), savedBlock(s))
+
+		s.drain()
+		require.Equal(t, uint64(2), savedBlock(s))
+	})
+}
+
 func TestGenerateUndoBlockMessages(t *testing.T) {
 
 	cursor := &sink.Cursor{
@@ -337,3 +649,83 @@ func TestGenerateUndoBlockMessages(t *testing.T) {
 
 	require.Equal(t, expectedResults, results)
 }
+
+type drainPublisher struct {
+	blockFlush bool
+	flushed    bool
+	closed     bool
+}
+
+func (p *drainPublisher) Publish(_ context.Context, _ []*pubsub.Message) []publisher.Result {
+	return nil
+}
+
+func (p *drainPublisher) Flush(ctx context.Context) error {
+	if p.blockFlush {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	p.flushed = true
+	return nil
+}
+
+func (p *drainPublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	newSink := func(pub publisher.Publisher) (*Sink, context.Context) {
+		drainCtx, stopStream := context.WithCancel(context.Background())
+		publishCtx, cancelPublish := context.WithCancel(context.Background())
+
+		return &Sink{
+			Shutter:       shutter.New(),
+			logger:        logger,
+			publisher:     pub,
+			drainTimeout:  50 * time.Millisecond,
+			drainCtx:      drainCtx,
+			stopStream:    stopStream,
+			publishCtx:    publishCtx,
+			cancelPublish: cancelPublish,
+		}, drainCtx
+	}
+
+	t.Run("flushes and closes", func(t *testing.T) {
+		pub := &drainPublisher{}
+		s, drainCtx := newSink(pub)
+
+		s.Drain()
+		s.Drain()
+		require.ErrorIs(t, drainCtx.Err(), context.Canceled)
+		require.NoError(t, s.publishCtx.Err())
+
+		s.drain()
+		require.True(t, pub.flushed)
+		require.True(t, pub.closed)
+
+		require.Eventually(t, func() bool { return s.publishCtx.Err() != nil }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("flush times out", func(t *testing.T) {
+		pub := &drainPublisher{blockFlush: true}
+		s, _ := newSink(pub)
+
+		start := time.Now()
+		s.drain()
+		require.Less(t, time.Since(start), time.Second)
+		require.False(t, pub.flushed)
+		require.True(t, pub.closed)
+	})
+}
+
+type countingCursorStore struct {
+	CursorStore
+	saves int
+}
+
+func (s *countingCursorStore) Save(cursor *sink.Cursor) error {
+	s.saves++
+	return s.CursorStore.Save(cursor)
+}
@@ -0,0 +1,188 @@
+package substreams_sink_pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Transformer changes the messages of a block before they are published, returning the
+// messages to publish. Returning an error stops the sink. Transformers are composed with
+// [Chain], see the built-in [Enrich], [BlockAttributes], [FilterMessages], [Compress],
+// [Chunk] and [RenameAttributes].
+type Transformer func(ctx context.Context, block *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error)
+
+// Filter returns false for the messages of a block that must not be published.
+type Filter func(block *BlockContext, message *pubsub.Message) bool
+
+// Chain returns a transformer applying the transformers in order, each one receiving the
+// messages returned by the previous one. Nil transformers are skipped.
+func Chain(transformers ...Transformer) Transformer {
+	var chain []Transformer
+	for _, transformer := range transformers {
+		if transformer != nil {
+			chain = append(chain, transformer)
+		}
+	}
+
+	if len(chain) == 1 {
+		return chain[0]
+	}
+
+	return func(ctx context.Context, block *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+		var err error
+		for _, transformer := range chain {
+			if messages, err = transformer(ctx, block, messages); err != nil {
+				return nil, err
+			}
+		}
+
+		return messages, nil
+	}
+}
+
+// Enrich returns a transformer calling enrich on each message, for example to add attributes
+// looked up from a local cache.
+func Enrich(enrich func(ctx context.Context, block *BlockContext, message *pubsub.Message) error) Transformer {
+	return func(ctx context.Context, block *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+		for _, message := range messages {
+			if err := enrich(ctx, block, message); err != nil {
+				return nil, err
+			}
+		}
+
+		return messages, nil
+	}
+}
+
+// BlockAttributes returns a transformer adding the 'BlockNumber', 'BlockID' and
+// 'BlockTimestamp' (RFC 3339) attributes of the block to each message.
+func BlockAttributes() Transformer {
+	return Enrich(func(_ context.Context, block *BlockContext, message *pubsub.Message) error {
+		setAttribute(message, "BlockNumber", strconv.FormatUint(block.Number, 10))
+		setAttribute(message, "BlockID", block.ID)
+		setAttribute(message, "BlockTimestamp", block.Timestamp.Format(time.RFC3339))
+		return nil
+	})
+}
+
+// FilterMessages returns a transformer keeping the messages kept by every filter.
+func FilterMessages(filters ...Filter) Transformer {
+	return func(_ context.Context, block *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+		kept := messages[:0]
+		for _, message := range messages {
+			if keep(filters, block, message) {
+				kept = append(kept, message)
+			}
+		}
+
+		return kept, nil
+	}
+}
+
+// Compress returns a transformer compressing the data of each message with gzip at level, see
+// [gzip.NewWriterLevel], and adding the 'ContentEncoding=gzip' attribute.
+func Compress(level int) Transformer {
+	return func(_ context.Context, _ *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+		for _, message := range messages {
+			var buffer bytes.Buffer
+			writer, err := gzip.NewWriterLevel(&buffer, level)
+			if err != nil {
+				return nil, fmt.Errorf("creating gzip writer: %w", err)
+			}
+
+			if _, err := writer.Write(message.Data); err != nil {
+				return nil, fmt.Errorf("compressing message: %w", err)
+			}
+			if err := writer.Close(); err != nil {
+				return nil, fmt.Errorf("compressing message: %w", err)
+			}
+
+			message.Data = buffer.Bytes()
+			setAttribute(message, "ContentEncoding", "gzip")
+		}
+
+		return messages, nil
+	}
+}
+
+// Chunk returns a transformer splitting the messages whose data is larger than size bytes in
+// chunks of at most size bytes. Chunks keep the message's attributes and ordering key, and
+// have the 'Chunk' (starting at 0) and 'Chunks' attributes, their data is concatenated in
+// 'Chunk' order to rebuild the message.
+func Chunk(size int) Transformer {
+	return func(_ context.Context, _ *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+		if size <= 0 {
+			return nil, fmt.Errorf("chunk size must be positive, got %d", size)
+		}
+
+		var out []*pubsub.Message
+		for _, message := range messages {
+			if len(message.Data) <= size {
+				out = append(out, message)
+				continue
+			}
+
+			chunks := (len(message.Data) + size - 1) / size
+			for i := 0; i < chunks; i++ {
+				attributes := make(map[string]string, len(message.Attributes)+2)
+				for key, value := range message.Attributes {
+					attributes[key] = value
+				}
+				attributes["Chunk"] = strconv.Itoa(i)
+				attributes["Chunks"] = strconv.Itoa(chunks)
+
+				out = append(out, &pubsub.Message{
+					ID:          fmt.Sprintf("%s.%d", message.ID, i),
+					Data:        message.Data[i*size : min((i+1)*size, len(message.Data))],
+					Attributes:  attributes,
+					OrderingKey: message.OrderingKey,
+				})
+			}
+		}
+
+		return out, nil
+	}
+}
+
+// RenameAttributes returns a transformer renaming the attributes of each message, renames
+// mapping the current name to the new one. Renames are applied at once, so that swapping two
+// names works.
+func RenameAttributes(renames map[string]string) Transformer {
+	return Enrich(func(_ context.Context, _ *BlockContext, message *pubsub.Message) error {
+		renamed := map[string]string{}
+		for from, to := range renames {
+			if value, found := message.Attributes[from]; found {
+				delete(message.Attributes, from)
+				renamed[to] = value
+			}
+		}
+
+		for key, value := range renamed {
+			message.Attributes[key] = value
+		}
+		return nil
+	})
+}
+
+func keep(filters []Filter, block *BlockContext, message *pubsub.Message) bool {
+	for _, filter := range filters {
+		if !filter(block, message) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func setAttribute(message *pubsub.Message, key, value string) {
+	if message.Attributes == nil {
+		message.Attributes = map[string]string{}
+	}
+	message.Attributes[key] = value
+}
@@ -0,0 +1,101 @@
+package substreams_sink_pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransformers(t *testing.T) {
+	ctx := context.Background()
+	block := &BlockContext{Number: 4, ID: "4a", Timestamp: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), Final: true}
+
+	t.Run("chain", func(t *testing.T) {
+		var calls []string
+		named := func(name string) Transformer {
+			return func(_ context.Context, _ *BlockContext, messages []*pubsub.Message) ([]*pubsub.Message, error) {
+				calls = append(calls, name)
+				return append(messages, &pubsub.Message{Data: []byte(name)}), nil
+			}
+		}
+
+		messages, err := Chain(named("a"), nil, named("b"))(ctx, block, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b"}, calls)
+		require.Len(t, messages, 2)
+
+		failing := func(context.Context, *BlockContext, []*pubsub.Message) ([]*pubsub.Message, error) {
+			return nil, errors.New("lookup failed")
+		}
+		calls = nil
+		_, err = Chain(named("a"), failing, named("b"))(ctx, block, nil)
+		require.EqualError(t, err, "lookup failed")
+		require.Equal(t, []string{"a"}, calls)
+	})
+
+	t.Run("block attributes", func(t *testing.T) {
+		messages, err := BlockAttributes()(ctx, block, []*pubsub.Message{{Data: []byte("data")}})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"BlockNumber": "4", "BlockID": "4a", "BlockTimestamp": "2023-11-14T22:13:20Z"}, messages[0].Attributes)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		messages, err := FilterMessages(func(_ *BlockContext, message *pubsub.Message) bool {
+			return message.Attributes["Type"] == "transfer"
+		})(ctx, block, []*pubsub.Message{
+			{Data: []byte("1"), Attributes: map[string]string{"Type": "transfer"}},
+			{Data: []byte("2"), Attributes: map[string]string{"Type": "approval"}},
+			{Data: []byte("3"), Attributes: map[string]string{"Type": "transfer"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		require.Equal(t, []byte("1"), messages[0].Data)
+		require.Equal(t, []byte("3"), messages[1].Data)
+	})
+
+	t.Run("compress", func(t *testing.T) {
+		data := bytes.Repeat([]byte("data"), 100)
+		messages, err := Compress(gzip.BestCompression)(ctx, block, []*pubsub.Message{{Data: data}})
+		require.NoError(t, err)
+		require.Equal(t, "gzip", messages[0].Attributes["ContentEncoding"])
+		require.Less(t, len(messages[0].Data), len(data))
+
+		reader, err := gzip.NewReader(bytes.NewReader(messages[0].Data))
+		require.NoError(t, err)
+		decompressed, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, data, decompressed)
+	})
+
+	t.Run("chunk", func(t *testing.T) {
+		messages, err := Chunk(4)(ctx, block, []*pubsub.Message{
+			{ID: "4-4a-0", Data: []byte("smal"), Attributes: map[string]string{"key": "value"}},
+			{ID: "4-4a-1", Data: []byte("0123456789"), Attributes: map[string]string{"key": "value"}, OrderingKey: "key"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []*pubsub.Message{
+			{ID: "4-4a-0", Data: []byte("smal"), Attributes: map[string]string{"key": "value"}},
+			{ID: "4-4a-1.0", Data: []byte("0123"), Attributes: map[string]string{"key": "value", "Chunk": "0", "Chunks": "3"}, OrderingKey: "key"},
+			{ID: "4-4a-1.1", Data: []byte("4567"), Attributes: map[string]string{"key": "value", "Chunk": "1", "Chunks": "3"}, OrderingKey: "key"},
+			{ID: "4-4a-1.2", Data: []byte("89"), Attributes: map[string]string{"key": "value", "Chunk": "2", "Chunks": "3"}, OrderingKey: "key"},
+		}, messages)
+
+		_, err = Chunk(0)(ctx, block, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("rename attributes", func(t *testing.T) {
+		messages, err := RenameAttributes(map[string]string{"a": "b", "b": "a", "c": "d"})(ctx, block, []*pubsub.Message{
+			{Attributes: map[string]string{"a": "1", "b": "2", "e": "5"}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"a": "2", "b": "1", "e": "5"}, messages[0].Attributes)
+	})
+}
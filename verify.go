@@ -0,0 +1,275 @@
+package substreams_sink_pubsub
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	sink "github.com/streamingfast/substreams-sink"
+)
+
+// BlockRange is an inclusive range of block numbers.
+type BlockRange struct {
+	Start uint64
+	Stop  uint64
+}
+
+func (r BlockRange) String() string {
+	if r.Start == r.Stop {
+		return fmt.Sprintf("#%d", r.Start)
+	}
+
+	return fmt.Sprintf("#%d-#%d", r.Start, r.Stop)
+}
+
+// OrderingViolation is a message received after a message of a later block sharing its
+// ordering key.
+type OrderingViolation struct {
+	OrderingKey string
+	BlockNumber uint64
+	AfterBlock  uint64
+}
+
+// CountMismatch is a block for which the number of messages received differs from the
+// expected one.
+type CountMismatch struct {
+	BlockNumber uint64
+	Expected    int
+	Actual      int
+}
+
+// VerifyReport is the outcome of a [Verifier].
+type VerifyReport struct {
+	Messages        int
+	Blocks          int
+	Gaps            []BlockRange
+	Duplicates      []string
+	OutOfOrder      []OrderingViolation
+	UnresolvedUndos []BlockRange
+	CountMismatches []CountMismatch
+}
+
+// OK returns true if the report found no problem.
+func (r *VerifyReport) OK() bool {
+	return len(r.Gaps) == 0 && len(r.Duplicates) == 0 && len(r.OutOfOrder) == 0 && len(r.UnresolvedUndos) == 0 && len(r.CountMismatches) == 0
+}
+
+// Verifier checks the messages published by the sink for a block range, received in the
+// order they were published, like in a file archive or an ordered subscription.
+//
+// Messages are identified by their ID when it's the one assigned by the sink, and otherwise,
+// like for PubSub which assigns its own IDs, by their block and a hash of their content.
+// Identical messages in the same block are thus reported as duplicates for such destinations.
+type Verifier struct {
+	blockRange BlockRange
+	expected   map[uint64]int
+
+	messages   int
+	counts     map[uint64]int
+	identities map[string]bool
+	duplicates []string
+	lastBlocks map[string]uint64
+	outOfOrder []OrderingViolation
+	undos      []uint64
+}
+
+// NewVerifier creates a [Verifier] for the messages of blockRange.
+func NewVerifier(blockRange BlockRange) *Verifier {
+	return &Verifier{
+		blockRange: blockRange,
+		counts:     map[uint64]int{},
+		identities: map[string]bool{},
+		lastBlocks: map[string]uint64{},
+	}
+}
+
+// ExpectCounts sets the number of messages expected for each block of the range, blocks
+// missing from counts are expected to have no message. Without expected counts, only the
+// blocks before the first message of the range and after its last one are reported as
+// gaps, as blocks without messages can't be told apart from missing ones.
+func (v *Verifier) ExpectCounts(counts map[uint64]int) {
+	v.expected = counts
+}
+
+// Add verifies the next message, messages without a valid 'Cursor' attribute, outside of the
+// block range, block end markers and heartbeats are ignored.
+func (v *Verifier) Add(message *pubsub.Message) {
+	cursor, err := sink.NewCursor(message.Attributes["Cursor"])
+	if err != nil || cursor.IsBlank() {
+		return
+	}
+
+	if step := message.Attributes["Step"]; step == "BlockEnd" || step == "Heartbeat" {
+		return
+	}
+
+	if message.Attributes["Step"] == "Undo" {
+		lastValidBlock, err := strconv.ParseUint(message.Attributes["LastValidBlock"], 10, 64)
+		if err != nil {
+			return
+		}
+
+		v.undo(lastValidBlock)
+		return
+	}
+
+	blockNum := cursor.Block().Num()
+	if blockNum < v.blockRange.Start || blockNum > v.blockRange.Stop {
+		return
+	}
+
+	v.messages++
+	v.counts[blockNum]++
+
+	identity := messageIdentity(message, cursor)
+	if v.identities[identity] {
+		v.duplicates = append(v.duplicates, identity)
+	}
+	v.identities[identity] = true
+
+	if message.OrderingKey != "" {
+		if last, found := v.lastBlocks[message.OrderingKey]; found && blockNum < last {
+			v.outOfOrder = append(v.outOfOrder, OrderingViolation{OrderingKey: message.OrderingKey, BlockNumber: blockNum, AfterBlock: last})
+		}
+		v.lastBlocks[message.OrderingKey] = blockNum
+	}
+
+	// A message for a block after an undo's last valid block means the chain moved on
+	var pending []uint64
+	for _, lastValidBlock := range v.undos {
+		if blockNum <= lastValidBlock {
+			pending = append(pending, lastValidBlock)
+		}
+	}
+	v.undos = pending
+}
+
+// undo forgets the messages of the blocks after lastValidBlock, they are replaced by the
+// messages of the blocks of the new chain segment.
+func (v *Verifier) undo(lastValidBlock uint64) {
+	for blockNum := range v.counts {
+		if blockNum > lastValidBlock {
+			v.messages -= v.counts[blockNum]
+			delete(v.counts, blockNum)
+		}
+	}
+
+	for key, last := range v.lastBlocks {
+		if last > lastValidBlock {
+			v.lastBlocks[key] = lastValidBlock
+		}
+	}
+
+	if lastValidBlock < v.blockRange.Stop {
+		v.undos = append(v.undos, lastValidBlock)
+	}
+}
+
+// Counts returns the number of messages added so far for each block, leaving out the blocks
+// that were undone.
+func (v *Verifier) Counts() map[uint64]int {
+	counts := make(map[uint64]int, len(v.counts))
+	for blockNum, count := range v.counts {
+		counts[blockNum] = count
+	}
+
+	return counts
+}
+
+// Report returns the problems found in the messages added so far.
+func (v *Verifier) Report() *VerifyReport {
+	report := &VerifyReport{
+		Messages:   v.messages,
+		Blocks:     len(v.counts),
+		Duplicates: v.duplicates,
+		OutOfOrder: v.outOfOrder,
+	}
+
+	if v.expected == nil {
+		report.Gaps = v.uncoveredRanges()
+	} else {
+		report.Gaps, report.CountMismatches = v.checkCounts()
+	}
+
+	for _, lastValidBlock := range v.undos {
+		report.UnresolvedUndos = append(report.UnresolvedUndos, BlockRange{Start: lastValidBlock + 1, Stop: v.blockRange.Stop})
+	}
+
+	return report
+}
+
+// checkCounts returns the ranges of blocks without messages that expected some, and the
+// blocks whose number of messages differs from the expected one.
+func (v *Verifier) checkCounts() (gaps []BlockRange, mismatches []CountMismatch) {
+	for blockNum := v.blockRange.Start; ; blockNum++ {
+		expected, actual := v.expected[blockNum], v.counts[blockNum]
+		if expected != actual && actual > 0 {
+			mismatches = append(mismatches, CountMismatch{BlockNumber: blockNum, Expected: expected, Actual: actual})
+		}
+
+		if expected > 0 && actual == 0 {
+			if len(gaps) > 0 && gaps[len(gaps)-1].Stop == blockNum-1 {
+				gaps[len(gaps)-1].Stop = blockNum
+			} else {
+				gaps = append(gaps, BlockRange{Start: blockNum, Stop: blockNum})
+			}
+		}
+
+		if blockNum == v.blockRange.Stop {
+			return gaps, mismatches
+		}
+	}
+}
+
+// uncoveredRanges returns the blocks of the range before the first block with messages and
+// after the last one, the whole range if no message was added. Blocks between them may
+// legitimately have no message, they are only checked against expected counts.
+func (v *Verifier) uncoveredRanges() []BlockRange {
+	if len(v.counts) == 0 {
+		return []BlockRange{v.blockRange}
+	}
+
+	first, last := v.blockRange.Stop, v.blockRange.Start
+	for blockNum := range v.counts {
+		first, last = min(first, blockNum), max(last, blockNum)
+	}
+
+	var ranges []BlockRange
+	if first > v.blockRange.Start {
+		ranges = append(ranges, BlockRange{Start: v.blockRange.Start, Stop: first - 1})
+	}
+	if last < v.blockRange.Stop {
+		ranges = append(ranges, BlockRange{Start: last + 1, Stop: v.blockRange.Stop})
+	}
+
+	return ranges
+}
+
+// messageIdentity returns the message's ID if it's the one assigned by the sink, see
+// [messageID], or an identity derived from its block and content otherwise.
+func messageIdentity(message *pubsub.Message, cursor *sink.Cursor) string {
+	prefix := fmt.Sprintf("%d-%s-", cursor.Block().Num(), cursor.Block().ID())
+	if strings.HasPrefix(message.ID, prefix) {
+		return message.ID
+	}
+
+	keys := make([]string, 0, len(message.Attributes))
+	for key := range message.Attributes {
+		if key != "Cursor" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	hash.Write(message.Data)
+	for _, key := range keys {
+		fmt.Fprintf(hash, "\x00%s=%s", key, message.Attributes[key])
+	}
+	fmt.Fprintf(hash, "\x00%s", message.OrderingKey)
+
+	return fmt.Sprintf("%s%x", prefix, hash.Sum(nil)[:8])
+}
@@ -0,0 +1,155 @@
+package substreams_sink_pubsub
+
+import (
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/streamingfast/bstream"
+	sink "github.com/streamingfast/substreams-sink"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+	cursor := func(blockNum uint64, blockID string) string {
+		block := bstream.NewBlockRef(blockID, blockNum)
+		return (&sink.Cursor{Cursor: &bstream.Cursor{Step: bstream.StepNew, Block: block, LIB: block, HeadBlock: block}}).String()
+	}
+
+	message := func(blockNum uint64, blockID string, index int, orderingKey string) *pubsub.Message {
+		return &pubsub.Message{
+			ID:          messageID(blockNum, blockID, index),
+			Data:        []byte("data"),
+			Attributes:  map[string]string{"Cursor": cursor(blockNum, blockID)},
+			OrderingKey: orderingKey,
+		}
+	}
+
+	undo := func(lastValidBlock uint64, blockID string) *pubsub.Message {
+		return &pubsub.Message{Attributes: map[string]string{"Cursor": cursor(lastValidBlock, blockID), "Step": "Undo", "LastValidBlock": "5"}}
+	}
+
+	tests := []struct {
+		name     string
+		messages []*pubsub.Message
+		expected map[uint64]int
+		want     *VerifyReport
+	}{
+		{
+			name:     "complete",
+			messages: []*pubsub.Message{message(1, "1a", 0, ""), message(2, "2a", 0, ""), message(2, "2a", 1, ""), message(3, "3a", 0, "")},
+			expected: map[uint64]int{1: 1, 2: 2, 3: 1},
+			want:     &VerifyReport{Messages: 4, Blocks: 3},
+		},
+		{
+			name: "block end markers",
+			messages: []*pubsub.Message{
+				message(1, "1a", 0, ""),
+				{Attributes: map[string]string{"Cursor": cursor(1, "1a"), "Step": "BlockEnd", "MessageCount": "1"}},
+				message(2, "2a", 0, ""),
+				{Attributes: map[string]string{"Cursor": cursor(2, "2a"), "Step": "BlockEnd", "MessageCount": "1"}},
+			},
+			expected: map[uint64]int{1: 1, 2: 1},
+			want:     &VerifyReport{Messages: 2, Blocks: 2},
+		},
+		{
+			name:     "gaps and duplicates",
+			messages: []*pubsub.Message{message(1, "1a", 0, ""), message(1, "1a", 0, ""), message(3, "3a", 0, ""), message(9, "9a", 0, "")},
+			want: &VerifyReport{
+				Messages:   4,
+				Blocks:     3,
+				Gaps:       []BlockRange{{Start: 10, Stop: 10}},
+				Duplicates: []string{"1-1a-0"},
+			},
+		},
+		{
+			name:     "uncovered range",
+			messages: []*pubsub.Message{message(3, "3a", 0, ""), message(7, "7a", 0, "")},
+			want: &VerifyReport{
+				Messages: 2,
+				Blocks:   2,
+				Gaps:     []BlockRange{{Start: 1, Stop: 2}, {Start: 8, Stop: 10}},
+			},
+		},
+		{
+			name: "no message",
+			want: &VerifyReport{Gaps: []BlockRange{{Start: 1, Stop: 10}}},
+		},
+		{
+			name:     "expected counts",
+			messages: []*pubsub.Message{message(1, "1a", 0, ""), message(3, "3a", 0, "")},
+			expected: map[uint64]int{1: 2, 3: 1, 4: 1},
+			want: &VerifyReport{
+				Messages:        2,
+				Blocks:          2,
+				Gaps:            []BlockRange{{Start: 4, Stop: 4}},
+				CountMismatches: []CountMismatch{{BlockNumber: 1, Expected: 2, Actual: 1}},
+			},
+		},
+		{
+			name:     "ordering",
+			messages: []*pubsub.Message{message(1, "1a", 0, "a"), message(3, "3a", 0, "a"), message(2, "2a", 0, "a"), message(2, "2a", 1, "b")},
+			expected: map[uint64]int{1: 1, 2: 2, 3: 1},
+			want: &VerifyReport{
+				Messages:   4,
+				Blocks:     3,
+				OutOfOrder: []OrderingViolation{{OrderingKey: "a", BlockNumber: 2, AfterBlock: 3}},
+			},
+		},
+		{
+			name: "resolved undo",
+			messages: []*pubsub.Message{
+				message(5, "5a", 0, "a"), message(6, "6a", 0, "a"), message(7, "7a", 0, "a"),
+				undo(5, "5a"),
+				message(6, "6b", 0, "a"), message(7, "7b", 0, "a"),
+			},
+			expected: map[uint64]int{5: 1, 6: 1, 7: 1},
+			want:     &VerifyReport{Messages: 3, Blocks: 3},
+		},
+		{
+			name: "unresolved undo",
+			messages: []*pubsub.Message{
+				message(5, "5a", 0, ""), message(6, "6a", 0, ""),
+				undo(5, "5a"),
+			},
+			expected: map[uint64]int{5: 1, 6: 1},
+			want: &VerifyReport{
+				Messages:        1,
+				Blocks:          1,
+				Gaps:            []BlockRange{{Start: 6, Stop: 6}},
+				UnresolvedUndos: []BlockRange{{Start: 6, Stop: 10}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier := NewVerifier(BlockRange{Start: 1, Stop: 10})
+			if test.expected != nil {
+				verifier.ExpectCounts(test.expected)
+			}
+
+			for _, message := range test.messages {
+				verifier.Add(message)
+			}
+
+			report := verifier.Report()
+			require.Equal(t, test.want, report)
+			require.Equal(t, report.OK(), test.name == "complete" || test.name == "block end markers" || test.name == "resolved undo")
+		})
+	}
+}
+
+func TestVerifierContentIdentity(t *testing.T) {
+	block := bstream.NewBlockRef("1a", 1)
+	cursor := (&sink.Cursor{Cursor: &bstream.Cursor{Step: bstream.StepNew, Block: block, LIB: block, HeadBlock: block}}).String()
+
+	verifier := NewVerifier(BlockRange{Start: 1, Stop: 1})
+	// PubSub assigns its own IDs, identity then derives from the content
+	verifier.Add(&pubsub.Message{ID: "1", Data: []byte("a"), Attributes: map[string]string{"Cursor": cursor}})
+	verifier.Add(&pubsub.Message{ID: "2", Data: []byte("b"), Attributes: map[string]string{"Cursor": cursor}})
+	verifier.Add(&pubsub.Message{ID: "3", Data: []byte("a"), Attributes: map[string]string{"Cursor": cursor}})
+
+	report := verifier.Report()
+	require.Len(t, report.Duplicates, 1)
+	require.Regexp(t, `^1-1a-[0-9a-f]{16}$`, report.Duplicates[0])
+}